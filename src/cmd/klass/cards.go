@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"isams_to_sheets/src/usersync"
+)
+
+func init() {
+	commands = append(commands,
+		command{
+			group:   "cards",
+			name:    "check",
+			summary: "Report card numbers shared across students, staff and families, invalid numbers and people without cards",
+			setup: func(fs *flag.FlagSet, g *globals) func() error {
+				cards := fs.String("cards", "", "P1 export CSV holding card numbers (default: cards.sources)")
+				return func() error {
+					isams, err := g.isams()
+					if err != nil {
+						return err
+					}
+					kf, err := g.kissflow()
+					if err != nil {
+						return err
+					}
+					cardSources, err := g.cardSources(*cards)
+					if err != nil {
+						return err
+					}
+					return usersync.CheckCards(isams, kf, os.Stdout, usersync.CardsOptions{
+						CardSources:  cardSources,
+						Cards:        g.cfg.Cards,
+						StaffDataset: g.cfg.Kissflow.StaffDataset,
+						StaffView:    g.cfg.Kissflow.StaffView,
+						Output:       g.output,
+					})
+				}
+			},
+		},
+	)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"isams_to_sheets/src/csvreport"
+)
+
+func init() {
+	commands = append(commands,
+		command{
+			group:   "csv",
+			name:    "group",
+			summary: "Count the rows of a CSV export by the value of one column",
+			setup: func(fs *flag.FlagSet, g *globals) func() error {
+				in := fs.String("in", "export.csv", "Path to input CSV file")
+				out := fs.String("out", "group_by_G.csv", "Path to output CSV summary file")
+				col := fs.String("col", "G", "Column to group by (letter like G or zero-based index like 6)")
+				header := fs.Bool("header", false, "Skip a header row in the input")
+				return func() error {
+					return csvreport.Group(os.Stdout, csvreport.GroupOptions{InPath: *in, OutPath: *out, Column: *col, Header: *header})
+				}
+			},
+		},
+		command{
+			group:   "csv",
+			name:    "families",
+			summary: "Extract the column G values containing FAMILY from the P1 export",
+			setup: func(fs *flag.FlagSet, g *globals) func() error {
+				in := fs.String("in", "", "P1 export CSV (default: files.card_csv)")
+				out := fs.String("out", "column_g_family.csv", "CSV file to write")
+				return func() error {
+					return csvreport.Families(g.file(*in, g.cfg.Files.CardCSV), *out)
+				}
+			},
+		},
+	)
+}
@@ -0,0 +1,216 @@
+// klass is the single entry point for the Alice Smith access control and
+// photo tooling. Run "klass help" for the list of commands.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"isams_to_sheets/src/common"
+
+	"github.com/joho/godotenv"
+)
+
+// globals are the flags shared by every command. They can be given before
+// the command name or among the command's own flags.
+type globals struct {
+	config   string
+	profile  string
+	envFile  string
+	logLevel string
+	dryRun   bool
+	output   string
+
+	// cfg is loaded by init.
+	cfg *common.Config
+}
+
+func (g *globals) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.config, "config", g.config, "Path to the YAML config file")
+	fs.StringVar(&g.profile, "profile", g.profile, "Config profile to use, e.g. staging or production (default: $KLASS_PROFILE or the file's default_profile)")
+	fs.StringVar(&g.envFile, "env", g.envFile, "Path to the .env file holding API keys")
+	fs.StringVar(&g.logLevel, "log-level", g.logLevel, "Log level: debug, info, warn or error")
+	fs.BoolVar(&g.dryRun, "dry-run", g.dryRun, "Show what would change without changing anything")
+	fs.StringVar(&g.output, "output", g.output, "Report format: text or json")
+}
+
+// init applies the global flags once all of them have been parsed.
+func (g *globals) init() error {
+	if err := common.SetLogLevel(g.logLevel); err != nil {
+		return err
+	}
+	if !common.ValidOutput(g.output) {
+		return fmt.Errorf("unknown output format %q (want text or json)", g.output)
+	}
+	if err := godotenv.Load(g.envFile); err != nil {
+		common.Debugf(".env file not loaded: %v", err)
+	}
+	cfg, err := common.LoadConfig(g.config, g.profile, g.config != common.DefaultConfigPath)
+	if err != nil {
+		return err
+	}
+	if cfg.Profile != "" {
+		common.Debugf("using config profile %s", cfg.Profile)
+	}
+	g.cfg = cfg
+	return nil
+}
+
+func (g *globals) isams() (*common.ISAMSClient, error) {
+	return g.cfg.ISAMSClient()
+}
+
+func (g *globals) kissflow() (*common.KissflowClient, error) {
+	return g.cfg.KissflowClient()
+}
+
+// file returns the path given on the command line, or when that is empty
+// the configured file resolved against the workspace root.
+func (g *globals) file(flagValue, configured string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	return g.cfg.Files.Path(configured)
+}
+
+// cardSources returns the P1 export given on the command line as the only
+// card source, or when that is empty the configured cards.sources.
+func (g *globals) cardSources(flagValue string) ([]common.CardSource, error) {
+	if flagValue != "" {
+		return []common.CardSource{common.NewP1CardSource(flagValue)}, nil
+	}
+	return g.cfg.CardSources()
+}
+
+// command is one "group name" subcommand. setup registers the command's
+// flags and returns the function that runs it once they are parsed.
+type command struct {
+	group   string
+	name    string
+	summary string
+	setup   func(fs *flag.FlagSet, g *globals) func() error
+}
+
+func (c command) fullName() string {
+	return c.group + " " + c.name
+}
+
+func main() {
+	g := &globals{config: common.DefaultConfigPath, envFile: ".env", logLevel: common.LogInfo, output: common.OutputText}
+
+	fs := flag.NewFlagSet("klass", flag.ExitOnError)
+	g.register(fs)
+	fs.Usage = func() { usage(fs) }
+	fs.Parse(os.Args[1:])
+
+	args := fs.Args()
+	if len(args) == 0 || args[0] == "help" {
+		if len(args) > 2 {
+			if cmd, _, ok := findCommand(args[1:]); ok {
+				cfs, _ := newCommandFlagSet(cmd, g)
+				cfs.Usage()
+				return
+			}
+		}
+		fs.Usage()
+		if len(args) == 0 {
+			os.Exit(2)
+		}
+		return
+	}
+	if len(args) < 2 {
+		fmt.Fprintf(os.Stderr, "klass: missing command after %q\n\n", args[0])
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cmd, n, ok := findCommand(args)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "klass: unknown command %q\n\n", args[0]+" "+args[1])
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfs, run := newCommandFlagSet(cmd, g)
+	cfs.Parse(args[n:])
+	if cfs.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "klass %s: unexpected arguments %v\n", cmd.fullName(), cfs.Args())
+		os.Exit(2)
+	}
+
+	if err := g.init(); err != nil {
+		fmt.Fprintf(os.Stderr, "klass: %v\n", err)
+		os.Exit(2)
+	}
+	if err := run(); err != nil {
+		common.Errorf("%s: %v", cmd.fullName(), err)
+		os.Exit(1)
+	}
+}
+
+func newCommandFlagSet(cmd command, g *globals) (*flag.FlagSet, func() error) {
+	cfs := flag.NewFlagSet("klass "+cmd.fullName(), flag.ExitOnError)
+	run := cmd.setup(cfs, g)
+	g.register(cfs)
+	cfs.Usage = func() {
+		fmt.Fprintf(cfs.Output(), "Usage: klass %s [flags]\n\n%s\n\nFlags:\n", cmd.fullName(), cmd.summary)
+		cfs.PrintDefaults()
+	}
+	return cfs, run
+}
+
+// findCommand looks up the command named by the leading args and returns it
+// with the number of args its name took. Names may have several words, e.g.
+// "photos cache stats".
+func findCommand(args []string) (command, int, bool) {
+	for _, c := range commands {
+		words := append([]string{c.group}, strings.Fields(c.name)...)
+		if len(args) < len(words) {
+			continue
+		}
+		match := true
+		for i, w := range words {
+			if args[i] != w {
+				match = false
+				break
+			}
+		}
+		if match {
+			return c, len(words), true
+		}
+	}
+	return command{}, 0, false
+}
+
+func usage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintln(w, "Usage: klass [global flags] <group> <command> [flags]")
+	fmt.Fprintln(w, "       klass help <group> <command>")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+
+	byGroup := make(map[string][]command)
+	var groups []string
+	for _, c := range commands {
+		if _, ok := byGroup[c.group]; !ok {
+			groups = append(groups, c.group)
+		}
+		byGroup[c.group] = append(byGroup[c.group], c)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		for _, c := range byGroup[group] {
+			summary := c.summary
+			if i := strings.Index(summary, "\n"); i >= 0 {
+				summary = summary[:i]
+			}
+			fmt.Fprintf(w, "  %-22s %s\n", c.fullName(), summary)
+		}
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Global flags:")
+	fs.PrintDefaults()
+}
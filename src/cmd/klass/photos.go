@@ -0,0 +1,267 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"isams_to_sheets/src/common"
+	"isams_to_sheets/src/photos"
+)
+
+func init() {
+	commands = append(commands,
+		command{
+			group:   "photos",
+			name:    "scan",
+			summary: "List students whose iSAMS photo original is larger than a threshold",
+			setup: func(fs *flag.FlagSet, g *globals) func() error {
+				thresholdKB := fs.Int("threshold-kb", 150, "Report photos whose original is at least this many KB")
+				out := fs.String("out", "", "CSV file to write (default: files.large_photo_list)")
+				return func() error {
+					isams, err := g.isams()
+					if err != nil {
+						return err
+					}
+					return photos.Scan(isams, photos.ScanOptions{ThresholdBytes: *thresholdKB * 1024, OutPath: g.file(*out, g.cfg.Files.LargePhotoList)})
+				}
+			},
+		},
+		command{
+			group:   "photos",
+			name:    "fix",
+			summary: "Recompress the photos listed by photos scan and upload them back to iSAMS",
+			setup: func(fs *flag.FlagSet, g *globals) func() error {
+				list := fs.String("list", "", "CSV written by photos scan (default: files.large_photo_list)")
+				report := fs.String("report", "photo_fix_report.csv", "CSV run report with the outcome and verification of each upload")
+				journal := fs.String("journal", "", "Journal of completed and failed uploads, used to resume (default: the list path + .journal)")
+				maxAttempts := fs.Int("max-attempts", photos.DefaultFixMaxAttempts, "Runs a failing photo is retried in before it is left for manual attention")
+				return func() error {
+					isams, err := g.isams()
+					if err != nil {
+						return err
+					}
+					opts := photos.FixOptions{ListPath: g.file(*list, g.cfg.Files.LargePhotoList), DryRun: g.dryRun, ReportPath: *report}
+					opts.JournalPath, opts.MaxAttempts = *journal, *maxAttempts
+					if !g.dryRun {
+						if opts.Backups, err = photoBackups(g); err != nil {
+							return err
+						}
+					}
+					return photos.Fix(isams, opts)
+				}
+			},
+		},
+		command{
+			group:   "photos",
+			name:    "rollback",
+			summary: "Restore the original iSAMS photos that photos fix replaced",
+			setup: func(fs *flag.FlagSet, g *globals) func() error {
+				run := fs.String("run", "", "Backup run to restore, as shown by -list")
+				ids := fs.String("ids", "", "Comma-separated school IDs to restore; without -run, from each student's latest backup")
+				list := fs.Bool("list", false, "List the backup runs instead of restoring")
+				return func() error {
+					backups, err := photoBackups(g)
+					if err != nil {
+						return err
+					}
+					if *list {
+						return photos.ListBackupRuns(os.Stdout, backups)
+					}
+					isams, err := g.isams()
+					if err != nil {
+						return err
+					}
+					return photos.Rollback(isams, photos.RollbackOptions{Backups: backups, RunID: *run, SchoolIds: commaList(*ids), DryRun: g.dryRun})
+				}
+			},
+		},
+		command{
+			group:   "photos",
+			name:    "export",
+			summary: "Write a ZIP of print-resolution student photos with a manifest for the card printing vendor",
+			setup: func(fs *flag.FlagSet, g *globals) func() error {
+				out := fs.String("out", "photos_export.zip", "ZIP archive to write")
+				years := fs.String("year", "", "Comma-separated year groups to export, e.g. 7,8")
+				forms := fs.String("form", "", "Comma-separated form groups to export")
+				ids := fs.String("ids", "", "File of school IDs to export, one per line")
+				cardCSV := fs.String("card-csv", "", "P1 export CSV for the manifest card numbers (default: cards.sources)")
+				maxWidth := fs.Int("max-width", 0, "Largest exported width (default: photos.print.max_width)")
+				maxHeight := fs.Int("max-height", 0, "Largest exported height (default: photos.print.max_height)")
+				quality := fs.Int("quality", 0, "JPEG quality of exported photos (default: photos.print.quality)")
+				concurrency := fs.Int("concurrency", 0, "Photos downloaded at once (default: isams.photo_concurrency)")
+				return func() error {
+					isams, err := g.isams()
+					if err != nil {
+						return err
+					}
+					cardSources, err := g.cardSources(*cardCSV)
+					if err != nil {
+						return err
+					}
+					opts := photos.ExportOptions{
+						OutPath:     *out,
+						YearGroups:  commaList(*years),
+						FormGroups:  commaList(*forms),
+						IDsPath:     *ids,
+						CardSources: cardSources,
+						Cards:       g.cfg.Cards,
+						Print:       g.cfg.Photos.Print,
+						Concurrency: *concurrency,
+					}
+					if *maxWidth > 0 {
+						opts.Print.MaxWidth = *maxWidth
+					}
+					if *maxHeight > 0 {
+						opts.Print.MaxHeight = *maxHeight
+					}
+					if *quality > 0 {
+						opts.Print.Quality = *quality
+					}
+					if opts.Concurrency <= 0 {
+						opts.Concurrency = g.cfg.ISAMS.PhotoConcurrency
+					}
+					return photos.Export(isams, opts)
+				}
+			},
+		},
+		command{
+			group:   "photos",
+			name:    "import",
+			summary: "Upload a folder of photos to iSAMS, matching files to students by school ID or name",
+			setup: func(fs *flag.FlagSet, g *globals) func() error {
+				dir := fs.String("dir", "", "Folder of photos named by school ID or student name")
+				minScore := fs.Float64("min-score", photos.DefaultImportMinScore, "Lowest name similarity (0-1) accepted as a match")
+				report := fs.String("report", "photo_import_report.csv", "CSV report of every file's match and outcome")
+				return func() error {
+					if *dir == "" {
+						return fmt.Errorf("-dir is required")
+					}
+					isams, err := g.isams()
+					if err != nil {
+						return err
+					}
+					opts := photos.ImportOptions{Dir: *dir, MinScore: *minScore, ReportPath: *report, Print: g.cfg.Photos.Print, DryRun: g.dryRun}
+					if !g.dryRun {
+						if opts.Backups, err = photoBackups(g); err != nil {
+							return err
+						}
+					}
+					return photos.Import(isams, os.Stdout, opts)
+				}
+			},
+		},
+		command{
+			group:   "photos",
+			name:    "audit",
+			summary: "Check every student photo for resolution, framing, exposure and compression problems",
+			setup: func(fs *flag.FlagSet, g *globals) func() error {
+				opts := photos.DefaultAuditOptions()
+				out := fs.String("out", "photo_audit.xlsx", "Report to write; .xlsx for a workbook, otherwise CSV")
+				fs.BoolVar(&opts.All, "all", false, "List every student, not only those needing a retake")
+				fs.IntVar(&opts.Concurrency, "concurrency", 0, "Photos downloaded at once (default: isams.photo_concurrency)")
+				fs.IntVar(&opts.MinWidth, "min-width", opts.MinWidth, "Flag photos narrower than this")
+				fs.IntVar(&opts.MinHeight, "min-height", opts.MinHeight, "Flag photos shorter than this")
+				fs.Float64Var(&opts.MinAspect, "min-aspect", opts.MinAspect, "Flag photos whose width/height is below this")
+				fs.Float64Var(&opts.MaxAspect, "max-aspect", opts.MaxAspect, "Flag photos whose width/height is above this")
+				fs.Float64Var(&opts.MinContrast, "min-contrast", opts.MinContrast, "Flag photos whose luminance spread (0-255) is below this as blank")
+				fs.Float64Var(&opts.MinBrightness, "min-brightness", opts.MinBrightness, "Flag photos whose mean luminance (0-255) is below this as too dark")
+				fs.Float64Var(&opts.MaxBrightness, "max-brightness", opts.MaxBrightness, "Flag photos whose mean luminance (0-255) is above this as overexposed")
+				fs.IntVar(&opts.MinSourceQuality, "min-quality", opts.MinSourceQuality, "Flag JPEG originals saved below this estimated quality")
+				return func() error {
+					isams, err := g.isams()
+					if err != nil {
+						return err
+					}
+					opts.OutPath = *out
+					if opts.Concurrency <= 0 {
+						opts.Concurrency = g.cfg.ISAMS.PhotoConcurrency
+					}
+					return photos.Audit(isams, opts)
+				}
+			},
+		},
+		command{
+			group:   "photos",
+			name:    "duplicates",
+			summary: "Group students whose photos are identical or near-identical, e.g. shared sibling photos or placeholders",
+			setup: func(fs *flag.FlagSet, g *globals) func() error {
+				maxDistance := fs.Int("max-distance", 4, "Largest perceptual hash distance (0-64) counted as the same photo; 0 finds exact matches only")
+				concurrency := fs.Int("concurrency", 0, "Photos fetched at once (default: isams.photo_concurrency)")
+				csvPath := fs.String("csv", "", "Also write the groups to this CSV file")
+				return func() error {
+					isams, err := g.isams()
+					if err != nil {
+						return err
+					}
+					if *concurrency <= 0 {
+						*concurrency = g.cfg.ISAMS.PhotoConcurrency
+					}
+					return photos.Duplicates(isams, os.Stdout, photos.DuplicatesOptions{
+						MaxDistance: *maxDistance,
+						Concurrency: *concurrency,
+						Output:      g.output,
+						CSVPath:     *csvPath,
+					})
+				}
+			},
+		},
+		command{
+			group:   "photos",
+			name:    "cache stats",
+			summary: "Show how many photos the local photo cache holds and how big it is",
+			setup: func(fs *flag.FlagSet, g *globals) func() error {
+				return func() error {
+					cache, err := photoCache(g)
+					if err != nil {
+						return err
+					}
+					return photos.CacheStats(os.Stdout, cache, g.output)
+				}
+			},
+		},
+		command{
+			group:   "photos",
+			name:    "cache purge",
+			summary: "Remove photos from the local photo cache",
+			setup: func(fs *flag.FlagSet, g *globals) func() error {
+				olderThan := fs.Duration("older-than", 0, "Only remove photos no sync has confirmed for this long, e.g. 720h (default: everything)")
+				id := fs.String("id", "", "Only remove this student's photo")
+				return func() error {
+					cache, err := photoCache(g)
+					if err != nil {
+						return err
+					}
+					return photos.CachePurge(cache, photos.PurgeOptions{SchoolId: *id, OlderThan: *olderThan, DryRun: g.dryRun})
+				}
+			},
+		},
+	)
+}
+
+func photoCache(g *globals) (*common.PhotoCache, error) {
+	cache := g.cfg.PhotoCache()
+	if cache == nil {
+		return nil, fmt.Errorf("the photo cache is disabled (photos.cache_dir is empty)")
+	}
+	return cache, nil
+}
+
+func photoBackups(g *globals) (*photos.BackupStore, error) {
+	if g.cfg.Photos.BackupDir == "" {
+		return nil, fmt.Errorf("photos.backup_dir is not set; originals must be backed up before photos are replaced")
+	}
+	return photos.NewBackupStore(g.file("", g.cfg.Photos.BackupDir)), nil
+}
+
+// commaList splits a comma-separated flag value, dropping empty items.
+func commaList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"isams_to_sheets/src/roster"
+)
+
+func init() {
+	commands = append(commands,
+		command{
+			group:   "roster",
+			name:    "match",
+			summary: "List JB students whose school ID is (or with -missing is not) in a roster workbook column",
+			setup: func(fs *flag.FlagSet, g *globals) func() error {
+				file := fs.String("file", "", "Path to the .xlsx roster (default: files.roster)")
+				sheet := fs.String("sheet", "", "Sheet name (default: first sheet)")
+				col := fs.String("col", "J", "Column letter to read IDs from")
+				out := fs.String("out", "", "Optional path to write CSV output (default: stdout); .txt writes tab-separated text")
+				missing := fs.Bool("missing", false, "Output students NOT present in the roster column")
+				return func() error {
+					isams, err := g.isams()
+					if err != nil {
+						return err
+					}
+					return roster.Match(isams, os.Stdout, roster.MatchOptions{File: g.file(*file, g.cfg.Files.Roster), Sheet: *sheet, Column: *col, OutPath: *out, Missing: *missing})
+				}
+			},
+		},
+	)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"isams_to_sheets/src/common"
+	"isams_to_sheets/src/usersync"
+)
+
+var commands []command
+
+// syncFlags registers the flags every User_Master sync shares.
+func syncFlags(fs *flag.FlagSet, g *globals) func() usersync.Options {
+	planPath := fs.String("plan", "", "Write the intended User_Master changes to this file instead of applying them")
+	forceDeletions := fs.Bool("force-deletions", false, "Apply deletions even when they exceed the mass-deletion guard limits")
+	serviceAccount := fs.String("service-account", "", "Google service account key used to write Sheets (default: sheets.service_account)")
+	return func() usersync.Options {
+		sheets := g.cfg.Sheets
+		if *serviceAccount != "" {
+			sheets.ServiceAccount = *serviceAccount
+		}
+		return usersync.Options{
+			PlanPath:       *planPath,
+			ForceDeletions: *forceDeletions,
+			DryRun:         g.dryRun,
+			Output:         g.output,
+			Sheets:         sheets,
+			Guard:          g.cfg.Guard,
+			Cards:          g.cfg.Cards,
+		}
+	}
+}
+
+func init() {
+	commands = append(commands,
+		command{
+			group:   "sync",
+			name:    "students",
+			summary: "Sync iSAMS students, photos and card numbers into User_Master and the students sheet",
+			setup: func(fs *flag.FlagSet, g *globals) func() error {
+				opts := syncFlags(fs, g)
+				cards := fs.String("cards", "", "P1 export CSV holding card numbers (default: cards.sources)")
+				concurrency := fs.Int("concurrency", 0, "Photos fetched at once (default: isams.photo_concurrency)")
+				rps := fs.Float64("rps", 0, "Maximum iSAMS requests per second (default: isams.requests_per_second)")
+				return func() error {
+					isams, err := g.isams()
+					if err != nil {
+						return err
+					}
+					if *rps > 0 {
+						isams.SetRateLimit(*rps)
+					}
+					if *concurrency <= 0 {
+						*concurrency = g.cfg.ISAMS.PhotoConcurrency
+					}
+					kf, err := g.kissflow()
+					if err != nil {
+						return err
+					}
+					cardSources, err := g.cardSources(*cards)
+					if err != nil {
+						return err
+					}
+					return usersync.Students(isams, kf, usersync.StudentsOptions{
+						Options:          opts(),
+						CardSources:      cardSources,
+						PhotoConcurrency: *concurrency,
+					})
+				}
+			},
+		},
+		command{
+			group:   "sync",
+			name:    "staff",
+			summary: "Sync active employees from Employee_Master into User_Master and the staff sheet",
+			setup: func(fs *flag.FlagSet, g *globals) func() error {
+				opts := syncFlags(fs, g)
+				cards := fs.String("cards", "", "P1 export CSV holding card numbers (default: cards.sources)")
+				return func() error {
+					kf, err := g.kissflow()
+					if err != nil {
+						return err
+					}
+					cardSources, err := g.cardSources(*cards)
+					if err != nil {
+						return err
+					}
+					return usersync.Staff(kf, usersync.StaffOptions{
+						Options:     opts(),
+						CardSources: cardSources,
+						Dataset:     g.cfg.Kissflow.StaffDataset,
+						View:        g.cfg.Kissflow.StaffView,
+					})
+				}
+			},
+		},
+		command{
+			group:   "sync",
+			name:    "parents",
+			summary: "Sync iSAMS family contacts into User_Master and the parents sheet",
+			setup: func(fs *flag.FlagSet, g *globals) func() error {
+				opts := syncFlags(fs, g)
+				return func() error {
+					kf, err := g.kissflow()
+					if err != nil {
+						return err
+					}
+					return usersync.Parents(kf, usersync.ParentsOptions{Options: opts(), Dataset: g.cfg.Kissflow.FamilyContactsDataset})
+				}
+			},
+		},
+		command{
+			group:   "sync",
+			name:    "families",
+			summary: "Sync family and driver cards from the card sources into User_Master",
+			setup: func(fs *flag.FlagSet, g *globals) func() error {
+				opts := syncFlags(fs, g)
+				cards := fs.String("cards", "", "P1 export CSV holding family cards (default: cards.sources)")
+				out := fs.String("out", "", "CSV report of the processed family rows (default: files.family_report)")
+				return func() error {
+					kf, err := g.kissflow()
+					if err != nil {
+						return err
+					}
+					cardSources, err := g.cardSources(*cards)
+					if err != nil {
+						return err
+					}
+					return usersync.Families(kf, usersync.FamiliesOptions{
+						Options:         opts(),
+						CardSources:     cardSources,
+						OutPath:         g.file(*out, g.cfg.Files.FamilyReport),
+						StudentsDataset: g.cfg.Kissflow.StudentsDataset,
+						StudentsView:    g.cfg.Kissflow.StudentsView,
+						KissflowExport:  g.cfg.Files.Path(g.cfg.Files.KissflowExport),
+					})
+				}
+			},
+		},
+		command{
+			group:   "sync",
+			name:    "others",
+			summary: "Sync contractors and other card holders from P1_OTHERS.csv into User_Master",
+			setup: func(fs *flag.FlagSet, g *globals) func() error {
+				opts := syncFlags(fs, g)
+				in := fs.String("in", "", "P1_OTHERS export CSV (default: files.others_csv)")
+				return func() error {
+					kf, err := g.kissflow()
+					if err != nil {
+						return err
+					}
+					return usersync.Others(kf, usersync.OthersOptions{Options: opts(), CSVPath: g.file(*in, g.cfg.Files.OthersCSV)})
+				}
+			},
+		},
+		command{
+			group:   "sync",
+			name:    "purge",
+			summary: "Delete User_Master records deactivated longer ago than their population's retention period",
+			setup: func(fs *flag.FlagSet, g *globals) func() error {
+				opts := syncFlags(fs, g)
+				pops := fs.String("population", strings.Join(common.Populations, ","), "Comma-separated populations to purge")
+				return func() error {
+					kf, err := g.kissflow()
+					if err != nil {
+						return err
+					}
+					return usersync.Purge(kf, usersync.PurgeOptions{Options: opts(), Populations: commaList(*pops), Retention: g.cfg.Retention})
+				}
+			},
+		},
+		command{
+			group:   "sync",
+			name:    "apply",
+			summary: "Apply a plan written by a sync with -plan, refusing if User_Master has changed since",
+			setup: func(fs *flag.FlagSet, g *globals) func() error {
+				planPath := fs.String("plan", "", "Plan file to apply (required)")
+				forceDeletions := fs.Bool("force-deletions", false, "Apply deletions even when they exceed the mass-deletion guard limits")
+				return func() error {
+					if *planPath == "" {
+						return fmt.Errorf("-plan is required")
+					}
+					kf, err := g.kissflow()
+					if err != nil {
+						return err
+					}
+					return usersync.Apply(kf, *planPath, usersync.Options{ForceDeletions: *forceDeletions, DryRun: g.dryRun, Output: g.output, Guard: g.cfg.Guard})
+				}
+			},
+		},
+	)
+}
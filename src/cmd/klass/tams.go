@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"isams_to_sheets/src/common"
+	"isams_to_sheets/src/tams"
+)
+
+func init() {
+	commands = append(commands,
+		command{
+			group:   "tams",
+			name:    "export",
+			summary: "Write the iSAMS student list into the TAMS students workbook",
+			setup: func(fs *flag.FlagSet, g *globals) func() error {
+				file := fs.String("file", "", "Path to the .xlsm file to update (default: files.tams_workbook)")
+				sheet := fs.String("sheet", common.DefaultTAMSSheet, "Sheet name to update")
+				pageSize := fs.Int("pagesize", 999, "Students API page size")
+				return func() error {
+					isams, err := g.isams()
+					if err != nil {
+						return err
+					}
+					isams.PageSize = *pageSize
+					return tams.Export(isams, tams.ExportOptions{File: g.file(*file, g.cfg.Files.TAMSWorkbook), Sheet: *sheet, DryRun: g.dryRun})
+				}
+			},
+		},
+		command{
+			group:   "tams",
+			name:    "inspect",
+			summary: "Print the sheets of a TAMS workbook with a preview of their rows",
+			setup: func(fs *flag.FlagSet, g *globals) func() error {
+				file := fs.String("file", "", "Path to the .xlsm/.xlsx file to read (default: files.tams_workbook)")
+				rows := fs.Int("rows", 10, "Maximum number of rows to print per sheet (preview)")
+				cols := fs.Int("cols", 10, "Maximum number of columns to print per row (preview)")
+				return func() error {
+					return tams.Inspect(os.Stdout, tams.InspectOptions{File: g.file(*file, g.cfg.Files.TAMSWorkbook), MaxRows: *rows, MaxCols: *cols})
+				}
+			},
+		},
+	)
+}
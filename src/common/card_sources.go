@@ -0,0 +1,291 @@
+package common
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Card source types, as named in the cards.sources config.
+const (
+	CardSourceCSV      = "csv"
+	CardSourceXLSX     = "xlsx"
+	CardSourceTAMS     = "tams"
+	CardSourceKissflow = "kissflow"
+)
+
+// Card merge rules between sources.
+const (
+	// CardMergeFirst keeps the card from the first source that has one.
+	CardMergeFirst = "first"
+	// CardMergeLatest lets each later source replace the card of an
+	// earlier one.
+	CardMergeLatest = "latest"
+)
+
+// CardSource supplies card rows. Rows carry the raw card number; the
+// registry parses and formats it.
+type CardSource interface {
+	// Name identifies the source in reports.
+	Name() string
+	Cards() ([]CardRow, error)
+}
+
+// CardColumns names the columns (or Kissflow fields) holding each value.
+// For spreadsheets without a header row they are column letters.
+type CardColumns struct {
+	ID         string `yaml:"id"`
+	Name       string `yaml:"name"`
+	Department string `yaml:"department"`
+	Number     string `yaml:"number"`
+}
+
+// P1CardColumns are the columns of the P1 export, which has no usable
+// header: A is the holder ID, B the name, G the department and J the card.
+var P1CardColumns = CardColumns{ID: "A", Name: "B", Department: "G", Number: "J"}
+
+// TAMSCardColumns are the headers of the TAMS students sheet.
+var TAMSCardColumns = CardColumns{ID: "Member Id", Name: "Name", Department: "Category", Number: "Smart Card No"}
+
+// DefaultTAMSSheet is the TAMS workbook sheet holding the students.
+const DefaultTAMSSheet = "EP Students"
+
+// TableCardSource reads cards from a CSV file or an XLSX/XLSM sheet.
+type TableCardSource struct {
+	Path string
+	// Sheet is the XLSX sheet; empty uses the first. Ignored for CSV.
+	Sheet   string
+	Columns CardColumns
+	// NoHeader treats every row as data and Columns as column letters.
+	NoHeader bool
+}
+
+// NewP1CardSource reads the P1 card export CSV.
+func NewP1CardSource(path string) *TableCardSource {
+	return &TableCardSource{Path: path, Columns: P1CardColumns, NoHeader: true}
+}
+
+// NewTAMSCardSource reads the "Smart Card No" column of a TAMS workbook.
+func NewTAMSCardSource(path, sheet string) *TableCardSource {
+	if sheet == "" {
+		sheet = DefaultTAMSSheet
+	}
+	return &TableCardSource{Path: path, Sheet: sheet, Columns: TAMSCardColumns}
+}
+
+func (s *TableCardSource) Name() string {
+	if s.Sheet != "" {
+		return s.Path + "!" + s.Sheet
+	}
+	return s.Path
+}
+
+func (s *TableCardSource) isXLSX() bool {
+	lower := strings.ToLower(s.Path)
+	return strings.HasSuffix(lower, ".xlsx") || strings.HasSuffix(lower, ".xlsm")
+}
+
+// Cards reads the table and maps its columns. Rows without an ID, or too
+// short to reach the number column, are skipped.
+func (s *TableCardSource) Cards() ([]CardRow, error) {
+	var rows [][]string
+	var err error
+	if s.isXLSX() {
+		rows, err = readXLSXRows(s.Path, s.Sheet)
+	} else {
+		rows, err = readCSVRows(s.Path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	idx, first, err := s.columnIndexes(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.Name(), err)
+	}
+	var cards []CardRow
+	for i := first; i < len(rows); i++ {
+		rec := rows[i]
+		if len(rec) <= idx.number {
+			continue
+		}
+		row := CardRow{
+			Source:     s.Name(),
+			Line:       i + 1,
+			ID:         cell(rec, idx.id),
+			Name:       cell(rec, idx.name),
+			Department: cell(rec, idx.department),
+			Raw:        cell(rec, idx.number),
+		}
+		if row.ID != "" {
+			cards = append(cards, row)
+		}
+	}
+	return cards, nil
+}
+
+type cardColumnIndexes struct {
+	id, name, department, number int
+}
+
+// columnIndexes resolves Columns to indexes and returns the first data row.
+func (s *TableCardSource) columnIndexes(rows [][]string) (cardColumnIndexes, int, error) {
+	resolve := func(col string) (int, error) {
+		return columnLetterIndex(col)
+	}
+	first := 0
+	if !s.NoHeader {
+		if len(rows) == 0 {
+			return cardColumnIndexes{}, 0, fmt.Errorf("no header row")
+		}
+		header := rows[0]
+		resolve = func(col string) (int, error) {
+			if col == "" {
+				return -1, nil
+			}
+			for i, h := range header {
+				if strings.EqualFold(strings.TrimSpace(h), col) {
+					return i, nil
+				}
+			}
+			return 0, fmt.Errorf("no %q column", col)
+		}
+		first = 1
+	}
+
+	var idx cardColumnIndexes
+	var err error
+	if idx.id, err = resolve(s.Columns.ID); err == nil && idx.id < 0 {
+		err = fmt.Errorf("no ID column configured")
+	}
+	if err == nil {
+		idx.name, err = resolve(s.Columns.Name)
+	}
+	if err == nil {
+		idx.department, err = resolve(s.Columns.Department)
+	}
+	if err == nil {
+		if idx.number, err = resolve(s.Columns.Number); err == nil && idx.number < 0 {
+			err = fmt.Errorf("no card number column configured")
+		}
+	}
+	return idx, first, err
+}
+
+// columnLetterIndex converts a column letter such as "J" to a 0-based
+// index; an empty letter returns -1.
+func columnLetterIndex(col string) (int, error) {
+	col = strings.ToUpper(strings.TrimSpace(col))
+	if col == "" {
+		return -1, nil
+	}
+	idx := 0
+	for _, c := range col {
+		if c < 'A' || c > 'Z' {
+			return 0, fmt.Errorf("%q is not a column letter", col)
+		}
+		idx = idx*26 + int(c-'A') + 1
+	}
+	return idx - 1, nil
+}
+
+func cell(rec []string, i int) string {
+	if i < 0 || i >= len(rec) {
+		return ""
+	}
+	return strings.TrimSpace(rec[i])
+}
+
+func readCSVRows(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open CSV: %w", err)
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	var rows [][]string
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read CSV: %w", err)
+		}
+		rows = append(rows, rec)
+	}
+}
+
+func readXLSXRows(path, sheet string) ([][]string, error) {
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("open workbook: %w", err)
+	}
+	defer f.Close()
+	if sheet == "" {
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, fmt.Errorf("workbook %s has no sheets", path)
+		}
+		sheet = sheets[0]
+	}
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return nil, fmt.Errorf("read sheet %q: %w", sheet, err)
+	}
+	return rows, nil
+}
+
+// KissflowCardSource reads cards from a Kissflow dataset or view; Columns
+// name its fields.
+type KissflowCardSource struct {
+	Client  *KissflowClient
+	Dataset string
+	// View, when set, lists the view instead of the whole dataset.
+	View    string
+	Columns CardColumns
+}
+
+func (s *KissflowCardSource) Name() string {
+	if s.View != "" {
+		return "kissflow:" + s.Dataset + "/" + s.View
+	}
+	return "kissflow:" + s.Dataset
+}
+
+// Cards lists the dataset. Line is the record's position in the listing.
+func (s *KissflowCardSource) Cards() ([]CardRow, error) {
+	if s.Columns.ID == "" || s.Columns.Number == "" {
+		return nil, fmt.Errorf("%s: the id and number fields must be configured", s.Name())
+	}
+	var records []map[string]interface{}
+	var err error
+	if s.View != "" {
+		records, err = ListView[map[string]interface{}](s.Client, s.Dataset, s.View)
+	} else {
+		records, err = ListDataset[map[string]interface{}](s.Client, s.Dataset)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.Name(), err)
+	}
+	var cards []CardRow
+	for i, rec := range records {
+		row := CardRow{
+			Source:     s.Name(),
+			Line:       i + 1,
+			ID:         FieldString(rec[s.Columns.ID]),
+			Name:       FieldString(rec[s.Columns.Name]),
+			Department: FieldString(rec[s.Columns.Department]),
+			Raw:        FieldString(rec[s.Columns.Number]),
+		}
+		if row.ID != "" {
+			cards = append(cards, row)
+		}
+	}
+	return cards, nil
+}
@@ -0,0 +1,208 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Card number formats, used both to describe how a number was written and
+// to choose how it is sent as CardNo.
+const (
+	// CardAsWritten sends a valid number exactly as the source wrote it,
+	// spaces removed, leading zeros kept.
+	CardAsWritten = "as-written"
+	// CardDecimal is the credential value as one decimal number, e.g.
+	// 8414507 for facility 128, number 25899.
+	CardDecimal = "decimal"
+	// CardHex is the credential value in upper-case hexadecimal, e.g.
+	// 80652B.
+	CardHex = "hex"
+	// CardWiegand26 is an 8-bit facility code and 16-bit number, written
+	// facility:number, e.g. 128:25899.
+	CardWiegand26 = "wiegand26"
+	// CardWiegand34 is a 16-bit facility code and 16-bit number.
+	CardWiegand34 = "wiegand34"
+)
+
+// ErrNoCard is returned by ParseCardNumber for an empty value.
+var ErrNoCard = errors.New("no card number")
+
+// CardFormats lists the formats a card number can be sent in.
+var CardFormats = []string{CardAsWritten, CardDecimal, CardHex, CardWiegand26, CardWiegand34}
+
+// CardNumber is a parsed card credential: the data bits of a 26- or 34-bit
+// Wiegand frame, without the parity bits.
+type CardNumber struct {
+	// Value is the credential, facility code in the high bits.
+	Value uint64
+	// Written is the format the number was parsed from.
+	Written string
+	// Text is the number as written, spaces removed.
+	Text string
+}
+
+// Facility is the facility code: the bits above the 16-bit card number.
+func (n CardNumber) Facility() uint64 { return n.Value >> 16 }
+
+// Number is the 16-bit card number within the facility.
+func (n CardNumber) Number() uint64 { return n.Value & 0xFFFF }
+
+// Bits is the smallest Wiegand frame the credential fits: 26 or 34.
+func (n CardNumber) Bits() int {
+	if n.Value < 1<<24 {
+		return 26
+	}
+	return 34
+}
+
+// Format writes the credential in format; CardAsWritten and "" return Text,
+// or the decimal value when there is none. Wiegand 26 fails for credentials
+// whose facility code needs more than 8 bits.
+func (n CardNumber) Format(format string) (string, error) {
+	switch format {
+	case CardAsWritten, "":
+		if n.Text != "" {
+			return n.Text, nil
+		}
+		return strconv.FormatUint(n.Value, 10), nil
+	case CardDecimal:
+		return strconv.FormatUint(n.Value, 10), nil
+	case CardHex:
+		return strings.ToUpper(strconv.FormatUint(n.Value, 16)), nil
+	case CardWiegand26:
+		if n.Bits() > 26 {
+			return "", fmt.Errorf("facility code %d does not fit 26-bit Wiegand", n.Facility())
+		}
+		return fmt.Sprintf("%d:%d", n.Facility(), n.Number()), nil
+	case CardWiegand34:
+		return fmt.Sprintf("%d:%d", n.Facility(), n.Number()), nil
+	}
+	return "", fmt.Errorf("unknown card format %q (known: %s)", format, strings.Join(CardFormats, ", "))
+}
+
+// Frame returns the full Wiegand frame of the credential with its leading
+// even and trailing odd parity bits, as readers transmit it. length is 26
+// or 34.
+func (n CardNumber) Frame(length int) (uint64, error) {
+	if length != 26 && length != 34 {
+		return 0, fmt.Errorf("unsupported Wiegand length %d", length)
+	}
+	data := length - 2
+	if n.Value >= 1<<data {
+		return 0, fmt.Errorf("card %d does not fit %d-bit Wiegand", n.Value, length)
+	}
+	half := uint(data / 2)
+	high := n.Value >> half
+	low := n.Value & (1<<half - 1)
+	even := uint64(bits.OnesCount64(high) % 2)
+	odd := uint64(1 - bits.OnesCount64(low)%2)
+	return even<<(length-1) | n.Value<<1 | odd, nil
+}
+
+// ParseCardNumber reads a card number as the P1 export writes it:
+//
+//   - decimal, optionally zero-padded: 8414507, 008414507
+//   - facility and number separated by ':', '-' or '/': 128:25899
+//   - hexadecimal with a 0x or h prefix or an H suffix, or mixing digits
+//     and the letters a-f: 0x80652B, 80652B
+//
+// Spaces are ignored. An empty value returns ErrNoCard, and zero, however
+// it is padded, is never a valid card.
+func ParseCardNumber(raw string) (CardNumber, error) {
+	s := strings.Join(strings.Fields(raw), "")
+	if s == "" {
+		return CardNumber{}, ErrNoCard
+	}
+
+	var n CardNumber
+	switch {
+	case strings.ContainsAny(s, ":-/"):
+		i := strings.IndexAny(s, ":-/")
+		facility, err := strconv.ParseUint(s[:i], 10, 64)
+		if err != nil {
+			return CardNumber{}, fmt.Errorf("facility code %q is not a number", s[:i])
+		}
+		number, err := strconv.ParseUint(s[i+1:], 10, 64)
+		if err != nil {
+			return CardNumber{}, fmt.Errorf("card number %q is not a number", s[i+1:])
+		}
+		if facility > 0xFFFF {
+			return CardNumber{}, fmt.Errorf("facility code %d is over 65535", facility)
+		}
+		if number > 0xFFFF {
+			return CardNumber{}, fmt.Errorf("card number %d is over 65535", number)
+		}
+		n = CardNumber{Value: facility<<16 | number, Written: CardWiegand34}
+		if facility <= 0xFF {
+			n.Written = CardWiegand26
+		}
+	case isHexCard(s):
+		digits := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(s), "0x"), "h"), "h")
+		v, err := strconv.ParseUint(digits, 16, 64)
+		if err != nil {
+			return CardNumber{}, fmt.Errorf("%q is not a hexadecimal card number", s)
+		}
+		n = CardNumber{Value: v, Written: CardHex}
+	default:
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return CardNumber{}, fmt.Errorf("%q is not a card number", s)
+		}
+		n = CardNumber{Value: v, Written: CardDecimal}
+	}
+	if n.Value == 0 {
+		return CardNumber{}, fmt.Errorf("card number is zero")
+	}
+	if n.Value >= 1<<32 {
+		return CardNumber{}, fmt.Errorf("%s is too large for 34-bit Wiegand", s)
+	}
+	n.Text = s
+	return n, nil
+}
+
+// isHexCard reports whether s carries one of the hexadecimal markers, or
+// is made of hex digits including both a letter and a decimal digit.
+func isHexCard(s string) bool {
+	l := strings.ToLower(s)
+	if strings.HasPrefix(l, "0x") || strings.HasPrefix(l, "h") || (strings.HasSuffix(l, "h") && len(l) > 1) {
+		return true
+	}
+	return strings.Trim(l, "0123456789abcdef") == "" && strings.ContainsAny(l, "abcdef") && strings.ContainsAny(l, "0123456789")
+}
+
+// IsPlaceholder reports whether raw, spaces removed, is one of the
+// placeholder numbers the card system gives cancelled or duplicate cards. A
+// placeholder ending in "*" matches every number starting with the rest.
+func IsPlaceholder(raw string, placeholders []string) bool {
+	s := strings.Join(strings.Fields(raw), "")
+	for _, p := range placeholders {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(s, prefix) {
+				return true
+			}
+		} else if s == p {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeCardNumber parses raw and writes it as cfg.Format. An empty raw
+// value returns "" without an error; the holder simply has no card. Numbers
+// listed in cfg.Placeholders are invalid.
+func NormalizeCardNumber(raw string, cfg CardsConfig) (string, error) {
+	if IsPlaceholder(raw, cfg.Placeholders) {
+		return "", fmt.Errorf("placeholder number")
+	}
+	n, err := ParseCardNumber(raw)
+	if errors.Is(err, ErrNoCard) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return n.Format(cfg.Format)
+}
@@ -0,0 +1,131 @@
+package common
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseCardNumber(t *testing.T) {
+	tests := []struct {
+		raw     string
+		value   uint64
+		written string
+		err     bool
+	}{
+		{raw: "8414507", value: 8414507, written: CardDecimal},
+		{raw: "008414507", value: 8414507, written: CardDecimal},
+		{raw: " 84 14507 ", value: 8414507, written: CardDecimal},
+		{raw: "128:25899", value: 8414507, written: CardWiegand26},
+		{raw: "128-25899", value: 8414507, written: CardWiegand26},
+		{raw: "128/25899", value: 8414507, written: CardWiegand26},
+		{raw: "300:1", value: 300<<16 | 1, written: CardWiegand34},
+		{raw: "0x80652B", value: 8414507, written: CardHex},
+		{raw: "80652Bh", value: 8414507, written: CardHex},
+		{raw: "H80652B", value: 8414507, written: CardHex},
+		{raw: "80652b", value: 8414507, written: CardHex},
+		// Digits only are never guessed to be hex.
+		{raw: "80652", value: 80652, written: CardDecimal},
+		// Letters only are not a card, hex or not.
+		{raw: "ABCDEF", err: true},
+		{raw: "00008414507", value: 8414507, written: CardDecimal},
+		{raw: "0", err: true},
+		{raw: "0000000", err: true},
+		{raw: "0:0", err: true},
+		{raw: "128:65536", err: true},
+		{raw: "65536:1", err: true},
+		{raw: "x:1", err: true},
+		{raw: "4294967296", err: true},
+		{raw: "12G45", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			n, err := ParseCardNumber(tt.raw)
+			if tt.err {
+				if err == nil {
+					t.Errorf("ParseCardNumber(%q) = %+v, want an error", tt.raw, n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCardNumber(%q): %v", tt.raw, err)
+			}
+			if n.Value != tt.value || n.Written != tt.written {
+				t.Errorf("ParseCardNumber(%q) = %d (%s), want %d (%s)", tt.raw, n.Value, n.Written, tt.value, tt.written)
+			}
+		})
+	}
+}
+
+func TestParseCardNumberEmpty(t *testing.T) {
+	if _, err := ParseCardNumber("  "); !errors.Is(err, ErrNoCard) {
+		t.Errorf("err = %v, want ErrNoCard", err)
+	}
+	if n, err := NormalizeCardNumber("", CardsConfig{Format: CardHex}); n != "" || err != nil {
+		t.Errorf("NormalizeCardNumber(\"\") = %q, %v, want no card and no error", n, err)
+	}
+}
+
+func TestCardNumberFormat(t *testing.T) {
+	n := CardNumber{Value: 8414507, Text: "008414507"}
+	for format, want := range map[string]string{
+		"":            "008414507",
+		CardAsWritten: "008414507",
+		CardDecimal:   "8414507",
+		CardHex:       "80652B",
+		CardWiegand26: "128:25899",
+		CardWiegand34: "128:25899",
+	} {
+		if got, err := n.Format(format); err != nil || got != want {
+			t.Errorf("Format(%q) = %q, %v, want %q", format, got, err, want)
+		}
+	}
+	if _, err := (CardNumber{Value: 300<<16 | 1}).Format(CardWiegand26); err == nil {
+		t.Error("facility 300 formatted as 26-bit Wiegand")
+	}
+	if _, err := n.Format("octal"); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+func TestNormalizeCardNumber(t *testing.T) {
+	placeholders := []string{"0000*", "9999999"}
+	tests := []struct {
+		raw    string
+		format string
+		want   string
+		err    bool
+	}{
+		{raw: "0 08414507", format: CardAsWritten, want: "008414507"},
+		{raw: "008414507", format: "", want: "008414507"},
+		{raw: "008414507", format: CardDecimal, want: "8414507"},
+		{raw: "80652b", format: CardAsWritten, want: "80652b"},
+		{raw: "00001234", format: CardAsWritten, err: true},
+		{raw: "9999999", format: CardDecimal, err: true},
+		{raw: "99999990", format: CardDecimal, want: "99999990"},
+		{raw: "000", format: CardAsWritten, err: true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeCardNumber(tt.raw, CardsConfig{Format: tt.format, Placeholders: placeholders})
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("NormalizeCardNumber(%q, %q) = %q, %v, want %q (error %v)", tt.raw, tt.format, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestCardNumberFrame(t *testing.T) {
+	// 26-bit: even parity over the high 12 data bits, odd over the low 12.
+	n := CardNumber{Value: 1}
+	frame, err := n.Frame(26)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := uint64(0b10); frame != want {
+		t.Errorf("Frame(26) = %b, want %b", frame, want)
+	}
+	if _, err := (CardNumber{Value: 1 << 24}).Frame(26); err == nil {
+		t.Error("25-bit credential fit a 26-bit frame")
+	}
+	if _, err := n.Frame(37); err == nil {
+		t.Error("37-bit frame accepted")
+	}
+}
@@ -0,0 +1,329 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Card holder populations.
+const (
+	CardStudents = "students"
+	CardStaff    = "staff"
+	CardFamilies = "families"
+)
+
+// CardRow is one card read from a card source.
+type CardRow struct {
+	// Source is the name of the source the row came from.
+	Source     string `json:"source,omitempty"`
+	Line       int    `json:"line"`
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Department string `json:"department"`
+	Raw        string `json:"raw"`
+	// Number is the normalized card number; empty when Raw is empty or
+	// invalid.
+	Number string `json:"number,omitempty"`
+	// Problem says why Raw is invalid.
+	Problem string `json:"problem,omitempty"`
+}
+
+// IsFamily reports whether the row is a family or driver card, which the
+// families sync owns.
+func (r CardRow) IsFamily() bool {
+	dept := strings.ToUpper(r.Department)
+	return (strings.Contains(dept, "FAMILY") || strings.Contains(dept, "DRIVER")) && !strings.Contains(dept, "FAMILY EXPERIENCE")
+}
+
+// Where names the source and line of the row for messages.
+func (r CardRow) Where() string {
+	return cardWhere(r.Source, r.Line)
+}
+
+func cardWhere(source string, line int) string {
+	if source == "" {
+		return fmt.Sprintf("line %d", line)
+	}
+	return fmt.Sprintf("%s line %d", source, line)
+}
+
+// CardRegistry holds every card read from the card sources, so all syncs
+// read and validate card numbers the same way.
+type CardRegistry struct {
+	// Sources names the sources the rows came from, in precedence order.
+	Sources []string
+	// Format is how Number is written; see CardFormats.
+	Format string
+	Rows   []CardRow
+	// byID indexes the non-family rows by ID, in source order.
+	byID map[string][]int
+}
+
+// NewCardRegistry returns a registry holding rows read from sources.
+func NewCardRegistry(sources []string, rows []CardRow) *CardRegistry {
+	r := &CardRegistry{Sources: sources, Rows: rows, byID: map[string][]int{}}
+	for i, row := range rows {
+		if !row.IsFamily() {
+			r.byID[row.ID] = append(r.byID[row.ID], i)
+		}
+	}
+	return r
+}
+
+// LoadCardRegistry reads the P1 export at csvPath; see NewP1CardSource.
+func LoadCardRegistry(csvPath string, cfg CardsConfig) (*CardRegistry, error) {
+	return LoadCards([]CardSource{NewP1CardSource(csvPath)}, cfg)
+}
+
+// LoadCards reads every source and merges their rows by cfg.Merge. Card
+// numbers are read with NormalizeCardNumber.
+//
+// Student and staff IDs found in several sources take their card from one
+// source only: with CardMergeFirst the first source listing a valid number
+// for the ID, with CardMergeLatest the last. The other sources' rows for the
+// ID are dropped, unless none has a valid number. Family and driver rows are
+// kept from every source.
+func LoadCards(sources []CardSource, cfg CardsConfig) (*CardRegistry, error) {
+	if _, err := (CardNumber{Value: 1}).Format(cfg.Format); err != nil {
+		return nil, err
+	}
+	merge := cfg.Merge
+	switch merge {
+	case "":
+		merge = CardMergeFirst
+	case CardMergeFirst, CardMergeLatest:
+	default:
+		return nil, fmt.Errorf("unknown card merge rule %q (known: %s, %s)", merge, CardMergeFirst, CardMergeLatest)
+	}
+
+	var names []string
+	var rows []CardRow
+	// from is the source index of each row.
+	var from []int
+	// winner is the source index each ID takes its card from.
+	winner := map[string]int{}
+	for i, src := range sources {
+		names = append(names, src.Name())
+		cards, err := src.Cards()
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range cards {
+			if row.Source == "" {
+				row.Source = src.Name()
+			}
+			if n, err := NormalizeCardNumber(row.Raw, cfg); err != nil {
+				row.Problem = err.Error()
+			} else {
+				row.Number = n
+			}
+			if row.Number != "" && !row.IsFamily() {
+				if _, ok := winner[row.ID]; !ok || merge == CardMergeLatest {
+					winner[row.ID] = i
+				}
+			}
+			rows = append(rows, row)
+			from = append(from, i)
+		}
+	}
+
+	kept := rows[:0]
+	for i, row := range rows {
+		if w, ok := winner[row.ID]; ok && !row.IsFamily() && from[i] != w {
+			continue
+		}
+		kept = append(kept, row)
+	}
+	rows = kept
+
+	reg := NewCardRegistry(names, rows)
+	reg.Format = cfg.Format
+	return reg, nil
+}
+
+// Lookup returns the card row for a student or staff ID: the first row with
+// a valid number, otherwise the first row at all. ok is false when the ID is
+// in no source.
+func (r *CardRegistry) Lookup(id string) (row CardRow, ok bool) {
+	idx := r.byID[id]
+	if len(idx) == 0 {
+		return CardRow{}, false
+	}
+	for _, i := range idx {
+		if r.Rows[i].Number != "" {
+			return r.Rows[i], true
+		}
+	}
+	return r.Rows[idx[0]], true
+}
+
+// Card returns the normalized card number of a student or staff ID, or "".
+func (r *CardRegistry) Card(id string) string {
+	row, _ := r.Lookup(id)
+	return row.Number
+}
+
+// CardHolder is a person a sync is about to send, with the card row it
+// took its number from (Line 0 when it has none).
+type CardHolder struct {
+	Population string `json:"population"`
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Number     string `json:"number,omitempty"`
+	Source     string `json:"source,omitempty"`
+	Line       int    `json:"line,omitempty"`
+}
+
+// Where names the source and line of the holder's card row for messages.
+func (h CardHolder) Where() string {
+	return cardWhere(h.Source, h.Line)
+}
+
+// cardRowKey identifies a row across sources.
+type cardRowKey struct {
+	source string
+	line   int
+}
+
+// Holder returns a student or staff member with their card.
+func (r *CardRegistry) Holder(population, id, name string) CardHolder {
+	h := CardHolder{Population: population, ID: id, Name: name}
+	if row, ok := r.Lookup(id); ok {
+		h.Number, h.Source, h.Line = row.Number, row.Source, row.Line
+	}
+	return h
+}
+
+// FamilyHolder returns the holder for a family row.
+func FamilyHolder(id string, row CardRow) CardHolder {
+	return CardHolder{Population: CardFamilies, ID: id, Name: row.Name, Number: row.Number, Source: row.Source, Line: row.Line}
+}
+
+// CardDuplicate is a card number held by more than one person.
+type CardDuplicate struct {
+	Number  string       `json:"number"`
+	Holders []CardHolder `json:"holders"`
+}
+
+// CardCheck lists the card problems found among a set of holders.
+type CardCheck struct {
+	Duplicates []CardDuplicate `json:"duplicates"`
+	Invalid    []CardRow       `json:"invalid"`
+	Missing    []CardHolder    `json:"missing"`
+}
+
+// Empty reports whether no problems were found.
+func (c *CardCheck) Empty() bool {
+	return len(c.Duplicates) == 0 && len(c.Invalid) == 0 && len(c.Missing) == 0
+}
+
+// Check reports the problems that concern holders: card numbers they share
+// with each other or with any other row of the sources, invalid numbers in
+// their rows, and holders without a card. Rows no holder took are
+// listed in duplicates under the population "unassigned".
+func (r *CardRegistry) Check(holders []CardHolder) *CardCheck {
+	check := &CardCheck{}
+	byNumber := map[string][]CardHolder{}
+	used := map[cardRowKey]bool{}
+	for _, h := range holders {
+		if h.Line > 0 {
+			used[cardRowKey{h.Source, h.Line}] = true
+		}
+		if h.Number != "" {
+			key := r.cardKey(h.Number)
+			byNumber[key] = append(byNumber[key], h)
+		}
+	}
+	for _, row := range r.Rows {
+		if used[cardRowKey{row.Source, row.Line}] || row.Number == "" {
+			continue
+		}
+		key := r.cardKey(row.Number)
+		hs, ok := byNumber[key]
+		if !ok || holdsRow(hs, row) {
+			continue
+		}
+		byNumber[key] = append(hs, CardHolder{Population: "unassigned", ID: row.ID, Name: row.Name, Number: row.Number, Source: row.Source, Line: row.Line})
+	}
+	for _, hs := range byNumber {
+		if len(hs) > 1 {
+			check.Duplicates = append(check.Duplicates, CardDuplicate{Number: hs[0].Number, Holders: hs})
+		}
+	}
+	sort.Slice(check.Duplicates, func(i, j int) bool { return check.Duplicates[i].Number < check.Duplicates[j].Number })
+
+	rowsByKey := map[cardRowKey]CardRow{}
+	for _, row := range r.Rows {
+		rowsByKey[cardRowKey{row.Source, row.Line}] = row
+	}
+	for _, h := range holders {
+		if h.Number != "" {
+			continue
+		}
+		if row, ok := rowsByKey[cardRowKey{h.Source, h.Line}]; ok && row.Problem != "" {
+			check.Invalid = append(check.Invalid, row)
+		} else {
+			check.Missing = append(check.Missing, h)
+		}
+	}
+	return check
+}
+
+// cardKey is the number Check compares cards by. Numbers sent as written
+// are compared by value, so a padded and an unpadded copy of one card are
+// still found to be shared.
+func (r *CardRegistry) cardKey(number string) string {
+	if r.Format != CardAsWritten && r.Format != "" {
+		return number
+	}
+	n, err := ParseCardNumber(number)
+	if err != nil {
+		return number
+	}
+	return strconv.FormatUint(n.Value, 10)
+}
+
+// holdsRow reports whether one of hs is the holder of row listed twice in
+// the sources.
+func holdsRow(hs []CardHolder, row CardRow) bool {
+	for _, h := range hs {
+		if h.ID == row.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// Summary is a one-line count of the problems.
+func (c *CardCheck) Summary() string {
+	return fmt.Sprintf("%d card numbers held by more than one person, %d invalid card numbers, %d people without a card", len(c.Duplicates), len(c.Invalid), len(c.Missing))
+}
+
+// Write prints every problem as text or JSON.
+func (c *CardCheck) Write(w io.Writer, format string) error {
+	if format == OutputJSON {
+		return WriteJSON(w, c)
+	}
+	fmt.Fprintln(w, c.Summary())
+	for _, d := range c.Duplicates {
+		fmt.Fprintf(w, "Card %s is held by %s\n", d.Number, describeHolders(d.Holders))
+	}
+	for _, row := range c.Invalid {
+		fmt.Fprintf(w, "Invalid card %q for %s %s (%s): %s\n", row.Raw, row.ID, row.Name, row.Where(), row.Problem)
+	}
+	for _, h := range c.Missing {
+		fmt.Fprintf(w, "No card for %s %s %s\n", h.Population, h.ID, h.Name)
+	}
+	return nil
+}
+
+func describeHolders(hs []CardHolder) string {
+	parts := make([]string, len(hs))
+	for i, h := range hs {
+		parts[i] = fmt.Sprintf("%s %s (%s, %s)", h.Population, h.ID, h.Name, h.Where())
+	}
+	return strings.Join(parts, ", ")
+}
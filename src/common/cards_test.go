@@ -0,0 +1,104 @@
+package common
+
+import "testing"
+
+type fakeCardSource struct {
+	name string
+	rows []CardRow
+}
+
+func (s fakeCardSource) Name() string              { return s.name }
+func (s fakeCardSource) Cards() ([]CardRow, error) { return s.rows, nil }
+
+func TestLoadCardsMerge(t *testing.T) {
+	p1 := fakeCardSource{name: "p1", rows: []CardRow{
+		{Line: 1, ID: "S1", Raw: "100"},
+		{Line: 2, ID: "S2", Raw: "0000123"},
+		{Line: 3, ID: "S3", Raw: "300"},
+		{Line: 4, ID: "12345A", Department: "Family", Raw: "400"},
+	}}
+	tams := fakeCardSource{name: "tams", rows: []CardRow{
+		{Line: 1, ID: "S1", Raw: "101"},
+		{Line: 2, ID: "S2", Raw: "200"},
+		{Line: 3, ID: "S4", Raw: ""},
+		{Line: 4, ID: "12345A", Department: "Family", Raw: "401"},
+	}}
+	tests := []struct {
+		merge string
+		cards map[string]string
+	}{
+		{merge: CardMergeFirst, cards: map[string]string{"S1": "100", "S2": "200", "S3": "300", "S4": ""}},
+		{merge: CardMergeLatest, cards: map[string]string{"S1": "101", "S2": "200", "S3": "300", "S4": ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.merge, func(t *testing.T) {
+			reg, err := LoadCards([]CardSource{p1, tams}, CardsConfig{Merge: tt.merge, Format: CardDecimal, Placeholders: []string{"0000*"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for id, want := range tt.cards {
+				if got := reg.Card(id); got != want {
+					t.Errorf("Card(%s) = %q, want %q", id, got, want)
+				}
+			}
+			// The losing source's rows for an ID are dropped.
+			for _, row := range reg.Rows {
+				if row.ID == "S1" && row.Number != tt.cards["S1"] {
+					t.Errorf("kept S1 row from %s", row.Where())
+				}
+			}
+			families := 0
+			for _, row := range reg.Rows {
+				if row.IsFamily() {
+					families++
+				}
+			}
+			if families != 2 {
+				t.Errorf("family rows = %d, want both sources' rows", families)
+			}
+		})
+	}
+}
+
+func TestLoadCardsKeepsInvalidWhenNoSourceIsValid(t *testing.T) {
+	a := fakeCardSource{name: "a", rows: []CardRow{{Line: 1, ID: "S1", Raw: "0000"}}}
+	b := fakeCardSource{name: "b", rows: []CardRow{{Line: 1, ID: "S1", Raw: "zz"}}}
+	reg, err := LoadCards([]CardSource{a, b}, CardsConfig{Merge: CardMergeFirst})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reg.Rows) != 2 {
+		t.Fatalf("rows = %d, want both invalid rows kept", len(reg.Rows))
+	}
+	row, ok := reg.Lookup("S1")
+	if !ok || row.Source != "a" || row.Problem == "" {
+		t.Errorf("Lookup(S1) = %+v, %v, want the first row with its problem", row, ok)
+	}
+}
+
+func TestLoadCardsRejectsUnknownRules(t *testing.T) {
+	if _, err := LoadCards(nil, CardsConfig{Merge: "newest"}); err == nil {
+		t.Error("unknown merge rule accepted")
+	}
+	if _, err := LoadCards(nil, CardsConfig{Format: "octal"}); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+func TestCardCheckComparesWrittenNumbersByValue(t *testing.T) {
+	src := fakeCardSource{name: "p1", rows: []CardRow{
+		{Line: 1, ID: "S1", Raw: "008414507"},
+		{Line: 2, ID: "T1", Raw: "8414507"},
+	}}
+	reg, err := LoadCards([]CardSource{src}, CardsConfig{Format: CardAsWritten})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reg.Card("S1"); got != "008414507" {
+		t.Errorf("Card(S1) = %q, want the padding kept", got)
+	}
+	check := reg.Check([]CardHolder{reg.Holder(CardStudents, "S1", "A"), reg.Holder(CardStaff, "T1", "B")})
+	if len(check.Duplicates) != 1 || len(check.Duplicates[0].Holders) != 2 {
+		t.Errorf("duplicates = %+v, want S1 and T1 sharing one card", check.Duplicates)
+	}
+}
@@ -0,0 +1,198 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+
+	"golang.org/x/image/draw"
+)
+
+// Compressor defaults. KissflowPhotoBudget is the largest image_1 payload
+// Kissflow accepts reliably.
+const (
+	KissflowPhotoBudget     = 37500
+	DefaultMinQuality       = 30
+	DefaultMaxQuality       = 90
+	DefaultPreferredQuality = 60
+	DefaultMinPhotoWidth    = 160
+	DefaultMinPhotoHeight   = 240
+	DefaultMaxPhotoWidth    = 600
+	DefaultMaxPhotoHeight   = 800
+	defaultScaleStep        = 0.85
+	// maxEncodes caps the JPEG encodings one photo may cost.
+	maxEncodes = 24
+
+	// photoPipelineVersion changes whenever decoding or normalization
+	// changes the output, so cached copies are remade.
+	photoPipelineVersion = 5
+)
+
+// ErrOverBudget is returned when no quality and size above the floors fits
+// the byte budget.
+var ErrOverBudget = errors.New("too large after resize")
+
+// Compressor encodes photos as JPEG within a byte budget. It searches quality
+// and scale together: the image is first shrunk to fit MaxWidth x MaxHeight,
+// then at each scale it tries PreferredQuality and stops at the first scale
+// where that fits, raising the quality as far as the budget allows. When no
+// scale reaches it, the largest scale that fits at all wins, at the highest
+// quality that fits there. Quality never drops below MinQuality, the image is
+// never shrunk below MinWidth x MinHeight, and no more than maxEncodes
+// encodings are tried.
+type Compressor struct {
+	MaxBytes         int
+	MinQuality       int
+	MaxQuality       int
+	PreferredQuality int
+	MinWidth         int
+	MinHeight        int
+	// MaxWidth and MaxHeight bound the first scale tried; zero leaves that
+	// side unbounded.
+	MaxWidth  int
+	MaxHeight int
+}
+
+// CompressResult is the encoding a Compressor chose.
+type CompressResult struct {
+	JPEG    []byte
+	Quality int
+	Width   int
+	Height  int
+}
+
+// NewCompressor returns a compressor with the default budget and floors.
+func NewCompressor() *Compressor {
+	return &Compressor{
+		MaxBytes:         KissflowPhotoBudget,
+		MinQuality:       DefaultMinQuality,
+		MaxQuality:       DefaultMaxQuality,
+		PreferredQuality: DefaultPreferredQuality,
+		MinWidth:         DefaultMinPhotoWidth,
+		MinHeight:        DefaultMinPhotoHeight,
+		MaxWidth:         DefaultMaxPhotoWidth,
+		MaxHeight:        DefaultMaxPhotoHeight,
+	}
+}
+
+// String identifies the settings, so cached output can be discarded when
+// they change.
+func (c *Compressor) String() string {
+	return fmt.Sprintf("v%d budget=%d q=%d-%d/%d min=%dx%d max=%dx%d", photoPipelineVersion, c.MaxBytes, c.MinQuality, c.MaxQuality, c.PreferredQuality, c.MinWidth, c.MinHeight, c.MaxWidth, c.MaxHeight)
+}
+
+// compressRun counts the encodings of one Compress call.
+type compressRun struct {
+	c       *Compressor
+	encodes int
+}
+
+// encode returns img as JPEG at quality q, or nil once the encode limit is
+// reached.
+func (r *compressRun) encode(img image.Image, q int) ([]byte, error) {
+	if r.encodes >= maxEncodes {
+		return nil, nil
+	}
+	r.encodes++
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: q}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Compress encodes img within the budget, or returns ErrOverBudget.
+func (c *Compressor) Compress(img image.Image) (*CompressResult, error) {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 {
+		return nil, fmt.Errorf("empty image")
+	}
+
+	start := 1.0
+	if c.MaxWidth > 0 && float64(c.MaxWidth)/float64(w) < start {
+		start = float64(c.MaxWidth) / float64(w)
+	}
+	if c.MaxHeight > 0 && float64(c.MaxHeight)/float64(h) < start {
+		start = float64(c.MaxHeight) / float64(h)
+	}
+
+	// Smaller scales are resampled from the pre-scaled image, so a large
+	// original is only read once.
+	base := img
+	if start < 1 {
+		base = resize(img, int(float64(w)*start), int(float64(h)*start))
+	}
+	run := &compressRun{c: c}
+	var best *CompressResult
+	for scale := start; run.encodes < maxEncodes; scale *= defaultScaleStep {
+		sw, sh := int(float64(w)*scale), int(float64(h)*scale)
+		if scale < start && (sw < max(c.MinWidth, 1) || sh < max(c.MinHeight, 1)) {
+			break
+		}
+		scaled := base
+		if scale < start {
+			scaled = resize(base, sw, sh)
+		}
+		preferred, err := run.bestQuality(scaled, c.PreferredQuality, c.MaxQuality)
+		if err != nil {
+			return nil, err
+		}
+		if preferred != nil {
+			return preferred, nil
+		}
+		if best == nil {
+			if best, err = run.bestQuality(scaled, c.MinQuality, c.PreferredQuality-1); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if best == nil {
+		return nil, ErrOverBudget
+	}
+	return best, nil
+}
+
+func resize(img image.Image, w, h int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, max(w, 1), max(h, 1)))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Over, nil)
+	return dst
+}
+
+// bestQuality finds the highest quality between lo and hi at which img fits
+// the budget. lo is tried first, so a scale where even lo is too large costs
+// one encoding. It returns nil when lo does not fit.
+func (r *compressRun) bestQuality(img image.Image, lo, hi int) (*CompressResult, error) {
+	if lo > hi {
+		return nil, nil
+	}
+	b := img.Bounds()
+	fit := func(q int) (*CompressResult, error) {
+		data, err := r.encode(img, q)
+		if err != nil || data == nil || len(data) > r.c.MaxBytes {
+			return nil, err
+		}
+		return &CompressResult{JPEG: data, Quality: q, Width: b.Dx(), Height: b.Dy()}, nil
+	}
+	best, err := fit(lo)
+	if best == nil || err != nil {
+		return nil, err
+	}
+	lo++
+	for lo <= hi {
+		q := (lo + hi) / 2
+		res, err := fit(q)
+		if err != nil {
+			return nil, err
+		}
+		if res != nil {
+			best = res
+			lo = q + 1
+		} else {
+			hi = q - 1
+		}
+	}
+	return best, nil
+}
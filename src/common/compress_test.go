@@ -0,0 +1,69 @@
+package common
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+)
+
+// noisy returns an image that compresses badly, like a detailed photo.
+func noisy(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	r := rand.New(rand.NewSource(1))
+	r.Read(img.Pix)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	return img
+}
+
+func TestCompress(t *testing.T) {
+	tests := []struct {
+		name      string
+		img       image.Image
+		maxWidth  int
+		maxHeight int
+		preferred bool
+	}{
+		{name: "small photo keeps its size", img: noisy(120, 160), maxWidth: 120, maxHeight: 160, preferred: true},
+		{name: "large photo is pre-scaled", img: noisy(3000, 4000), maxWidth: DefaultMaxPhotoWidth, maxHeight: DefaultMaxPhotoHeight},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCompressor()
+			c.MinWidth, c.MinHeight = 40, 40
+			res, err := c.Compress(tt.img)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.JPEG) > c.MaxBytes {
+				t.Errorf("%d bytes is over the %d budget", len(res.JPEG), c.MaxBytes)
+			}
+			if res.Width > tt.maxWidth || res.Height > tt.maxHeight {
+				t.Errorf("size %dx%d, want at most %dx%d", res.Width, res.Height, tt.maxWidth, tt.maxHeight)
+			}
+			if tt.preferred && res.Quality < c.PreferredQuality {
+				t.Errorf("quality %d, want at least %d", res.Quality, c.PreferredQuality)
+			}
+		})
+	}
+}
+
+func TestCompressEncodeLimit(t *testing.T) {
+	c := NewCompressor()
+	c.MaxBytes = 100 // nothing fits
+	c.MinWidth, c.MinHeight = 1, 1
+	run := &compressRun{c: c}
+	for i := 0; i < maxEncodes+5; i++ {
+		data, err := run.encode(noisy(8, 8), 50)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (data == nil) != (i >= maxEncodes) {
+			t.Fatalf("encode %d returned data=%v", i, data != nil)
+		}
+	}
+	if _, err := c.Compress(noisy(600, 800)); err != ErrOverBudget {
+		t.Errorf("Compress = %v, want ErrOverBudget", err)
+	}
+}
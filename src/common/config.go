@@ -0,0 +1,545 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultConfigPath is the config file read when -config is not given.
+const DefaultConfigPath = "klass.yaml"
+
+// Config holds every endpoint, ID, path and size the tools use. It is built
+// from the defaults below, then the config file (its top level, then the
+// selected profile on top), then environment variables. Each field's env tag
+// lists the variables that override it; the first one set wins.
+type Config struct {
+	// Profile is the name of the profile that was applied, if any.
+	Profile string `yaml:"-"`
+
+	ISAMS    ISAMSConfig    `yaml:"isams"`
+	Kissflow KissflowConfig `yaml:"kissflow"`
+	Sheets   SheetsConfig   `yaml:"sheets"`
+	Files    FilesConfig    `yaml:"files"`
+	Photos   PhotosConfig   `yaml:"photos"`
+	Guard    GuardConfig    `yaml:"guard"`
+	Cards    CardsConfig    `yaml:"cards"`
+	// Retention is per population; see RetentionConfig.
+	Retention RetentionConfig `yaml:"retention"`
+}
+
+type ISAMSConfig struct {
+	BaseURL   string `yaml:"base_url" env:"KLASS_ISAMS_BASE_URL,ISAMS_BASE_URL"`
+	APIKeyURL string `yaml:"api_key_url" env:"KLASS_ISAMS_API_KEY_URL,API_KEY_URL"`
+	PageSize  int    `yaml:"page_size" env:"KLASS_ISAMS_PAGE_SIZE"`
+	// RequestsPerSecond caps the request rate against iSAMS; zero disables
+	// the cap.
+	RequestsPerSecond float64 `yaml:"requests_per_second" env:"KLASS_ISAMS_REQUESTS_PER_SECOND"`
+	// PhotoConcurrency is the number of photos fetched at once.
+	PhotoConcurrency int `yaml:"photo_concurrency" env:"KLASS_ISAMS_PHOTO_CONCURRENCY"`
+	// UploadAttempts is how many times a photo upload is retried while the
+	// photo iSAMS serves back does not match what was sent.
+	UploadAttempts int `yaml:"upload_attempts" env:"KLASS_ISAMS_UPLOAD_ATTEMPTS"`
+}
+
+type KissflowConfig struct {
+	BaseURL         string `yaml:"base_url" env:"KLASS_KISSFLOW_BASE_URL"`
+	AccountID       string `yaml:"account_id" env:"KLASS_KISSFLOW_ACCOUNT_ID"`
+	AccessKeyId     string `yaml:"access_key_id" env:"KLASS_KISSFLOW_ACCESS_KEY_ID,X_ACCESS_KEY_ID_VALUE"`
+	AccessKeySecret string `yaml:"access_key_secret" env:"KLASS_KISSFLOW_ACCESS_KEY_SECRET,X_ACCESS_KEY_SECRET_VALUE"`
+	PageSize        int    `yaml:"page_size" env:"KLASS_KISSFLOW_PAGE_SIZE"`
+	BatchSize       int    `yaml:"batch_size" env:"KLASS_KISSFLOW_BATCH_SIZE"`
+
+	UserMasterDataset     string `yaml:"user_master_dataset" env:"KLASS_KISSFLOW_USER_MASTER_DATASET"`
+	StaffDataset          string `yaml:"staff_dataset" env:"KLASS_KISSFLOW_STAFF_DATASET"`
+	StaffView             string `yaml:"staff_view" env:"KLASS_KISSFLOW_STAFF_VIEW"`
+	FamilyContactsDataset string `yaml:"family_contacts_dataset" env:"KLASS_KISSFLOW_FAMILY_CONTACTS_DATASET"`
+	// StudentsDataset and StudentsView hold the students' parentMembershipNo
+	// and enrollmentStatus, which decide whether a family is active. There
+	// is no default: each deployment must set it. When unset, or when
+	// Kissflow cannot be read, files.kissflow_export is used with a warning.
+	StudentsDataset string `yaml:"students_dataset" env:"KLASS_KISSFLOW_STUDENTS_DATASET"`
+	StudentsView    string `yaml:"students_view" env:"KLASS_KISSFLOW_STUDENTS_VIEW"`
+}
+
+// DatasetURL is the root of the account's dataset API.
+func (k KissflowConfig) DatasetURL() string {
+	return fmt.Sprintf("%s/dataset/2/%s", strings.TrimRight(k.BaseURL, "/"), k.AccountID)
+}
+
+type SheetsConfig struct {
+	SpreadsheetID  string `yaml:"spreadsheet_id" env:"KLASS_SHEETS_SPREADSHEET_ID"`
+	ServiceAccount string `yaml:"service_account" env:"KLASS_SHEETS_SERVICE_ACCOUNT"`
+	StudentsTab    string `yaml:"students_tab" env:"KLASS_SHEETS_STUDENTS_TAB"`
+	StaffTab       string `yaml:"staff_tab" env:"KLASS_SHEETS_STAFF_TAB"`
+	ParentsTab     string `yaml:"parents_tab" env:"KLASS_SHEETS_PARENTS_TAB"`
+	BatchSize      int    `yaml:"batch_size" env:"KLASS_SHEETS_BATCH_SIZE"`
+}
+
+// FilesConfig lists the input and output files. Relative paths are resolved
+// against WorkspaceRoot.
+type FilesConfig struct {
+	WorkspaceRoot  string `yaml:"workspace_root" env:"KLASS_WORKSPACE_ROOT"`
+	CardCSV        string `yaml:"card_csv" env:"KLASS_CARD_CSV"`
+	KissflowExport string `yaml:"kissflow_export" env:"KLASS_KISSFLOW_EXPORT"`
+	OthersCSV      string `yaml:"others_csv" env:"KLASS_OTHERS_CSV"`
+	FamilyReport   string `yaml:"family_report" env:"KLASS_FAMILY_REPORT"`
+	LargePhotoList string `yaml:"large_photo_list" env:"KLASS_LARGE_PHOTO_LIST"`
+	TAMSWorkbook   string `yaml:"tams_workbook" env:"KLASS_TAMS_WORKBOOK"`
+	Roster         string `yaml:"roster" env:"KLASS_ROSTER"`
+}
+
+// Path resolves a configured file name against the workspace root.
+func (f FilesConfig) Path(name string) string {
+	if name == "" || filepath.IsAbs(name) || f.WorkspaceRoot == "" {
+		return name
+	}
+	return filepath.Join(f.WorkspaceRoot, name)
+}
+
+// PhotosConfig controls how student photos are cached and processed.
+type PhotosConfig struct {
+	// CacheDir holds the photo cache, resolved like the other files. Empty
+	// disables the cache.
+	CacheDir string `yaml:"cache_dir" env:"KLASS_PHOTO_CACHE_DIR"`
+	// CacheMaxAgeDays is how long a photo no sync has seen stays cached.
+	CacheMaxAgeDays int `yaml:"cache_max_age_days" env:"KLASS_PHOTO_CACHE_MAX_AGE_DAYS"`
+	// BackupDir keeps the original of every photo a fix replaces, resolved
+	// like the other files.
+	BackupDir string `yaml:"backup_dir" env:"KLASS_PHOTO_BACKUP_DIR"`
+
+	// MaxBytes is the compressed photo budget; the other fields are the
+	// Compressor's quality and resolution floors.
+	MaxBytes         int `yaml:"max_bytes" env:"KLASS_PHOTO_MAX_BYTES"`
+	MinQuality       int `yaml:"min_quality" env:"KLASS_PHOTO_MIN_QUALITY"`
+	MaxQuality       int `yaml:"max_quality" env:"KLASS_PHOTO_MAX_QUALITY"`
+	PreferredQuality int `yaml:"preferred_quality" env:"KLASS_PHOTO_PREFERRED_QUALITY"`
+	MinWidth         int `yaml:"min_width" env:"KLASS_PHOTO_MIN_WIDTH"`
+	MinHeight        int `yaml:"min_height" env:"KLASS_PHOTO_MIN_HEIGHT"`
+	// MaxWidth and MaxHeight bound the size compression starts from.
+	MaxWidth  int `yaml:"max_width" env:"KLASS_PHOTO_MAX_WIDTH"`
+	MaxHeight int `yaml:"max_height" env:"KLASS_PHOTO_MAX_HEIGHT"`
+
+	// Formats lists the photo formats to decode, out of jpeg, png, gif,
+	// bmp, tiff and webp. Empty enables all of them.
+	Formats []string `yaml:"formats" env:"KLASS_PHOTO_FORMATS"`
+
+	// Print is the size and quality of photos exported for card printing.
+	Print PrintConfig `yaml:"print"`
+}
+
+// PrintConfig is the photo profile for card printing: photos are shrunk to
+// fit MaxWidth x MaxHeight, never enlarged, and saved at Quality.
+type PrintConfig struct {
+	MaxWidth  int `yaml:"max_width" env:"KLASS_PRINT_MAX_WIDTH"`
+	MaxHeight int `yaml:"max_height" env:"KLASS_PRINT_MAX_HEIGHT"`
+	Quality   int `yaml:"quality" env:"KLASS_PRINT_QUALITY"`
+}
+
+// CardsConfig controls where card numbers are read from and how they are
+// sent.
+type CardsConfig struct {
+	// Format is how CardNo is written: as-written (the default, exactly as
+	// the source has it), or converted to decimal, hex, wiegand26 or
+	// wiegand34 (facility:number).
+	Format string `yaml:"format" env:"KLASS_CARD_FORMAT"`
+	// Placeholders are the numbers the card system gives cancelled or
+	// duplicate cards, sent as no card; one ending in "*" is a prefix.
+	// Zero is always invalid.
+	Placeholders []string `yaml:"placeholders" env:"KLASS_CARD_PLACEHOLDERS"`
+	// Merge decides which source's card a person keeps when several list
+	// one: first or latest.
+	Merge string `yaml:"merge" env:"KLASS_CARD_MERGE"`
+	// Sources are read in order. Empty reads the P1 export at
+	// files.card_csv.
+	Sources []CardSourceConfig `yaml:"sources"`
+}
+
+// CardSourceConfig describes one card source.
+type CardSourceConfig struct {
+	// Type is csv, xlsx, tams or kissflow.
+	Type string `yaml:"type"`
+	// Path is the csv or xlsx file, resolved like the other files. tams
+	// defaults to files.tams_workbook.
+	Path  string `yaml:"path"`
+	Sheet string `yaml:"sheet"`
+	// NoHeader reads every row as data, with Columns as column letters.
+	NoHeader bool        `yaml:"no_header"`
+	Columns  CardColumns `yaml:"columns"`
+	// Dataset and View select the kissflow records.
+	Dataset string `yaml:"dataset"`
+	View    string `yaml:"view"`
+}
+
+// User_Master populations, each owned by one sync.
+const (
+	PopulationStudents = "students"
+	PopulationStaff    = "staff"
+	PopulationParents  = "parents"
+	PopulationFamilies = "families"
+	PopulationOthers   = "others"
+)
+
+// Populations lists every population.
+var Populations = []string{PopulationStudents, PopulationStaff, PopulationParents, PopulationFamilies, PopulationOthers}
+
+// DefaultRetentionDays is how long a deactivated record is kept by default.
+const DefaultRetentionDays = 365
+
+// RetentionConfig is how many days each population's deactivated
+// User_Master records are kept before klass sync purge deletes them. Zero
+// keeps them forever.
+type RetentionConfig struct {
+	Students int `yaml:"students" env:"KLASS_RETENTION_STUDENTS"`
+	Staff    int `yaml:"staff" env:"KLASS_RETENTION_STAFF"`
+	Parents  int `yaml:"parents" env:"KLASS_RETENTION_PARENTS"`
+	Families int `yaml:"families" env:"KLASS_RETENTION_FAMILIES"`
+	Others   int `yaml:"others" env:"KLASS_RETENTION_OTHERS"`
+}
+
+// RetentionDays returns the retention period of a population, in days.
+func (r RetentionConfig) RetentionDays(population string) (int, error) {
+	switch population {
+	case PopulationStudents:
+		return r.Students, nil
+	case PopulationStaff:
+		return r.Staff, nil
+	case PopulationParents:
+		return r.Parents, nil
+	case PopulationFamilies:
+		return r.Families, nil
+	case PopulationOthers:
+		return r.Others, nil
+	}
+	return 0, fmt.Errorf("unknown population %q (known: %s)", population, strings.Join(Populations, ", "))
+}
+
+// GuardConfig sets the mass-deletion guard limits; see DeletionGuard.
+type GuardConfig struct {
+	MaxDeletes           int     `yaml:"max_deletes" env:"KLASS_GUARD_MAX_DELETES"`
+	MaxDeletePercent     float64 `yaml:"max_delete_percent" env:"KLASS_GUARD_MAX_DELETE_PERCENT"`
+	MaxSourceDropPercent float64 `yaml:"max_source_drop_percent" env:"KLASS_GUARD_MAX_SOURCE_DROP_PERCENT"`
+	StatePath            string  `yaml:"state_path" env:"KLASS_GUARD_STATE_PATH"`
+	ReportDir            string  `yaml:"report_dir" env:"KLASS_GUARD_REPORT_DIR"`
+}
+
+// DefaultConfig returns the production settings used when nothing overrides
+// them.
+func DefaultConfig() *Config {
+	return &Config{
+		ISAMS: ISAMSConfig{
+			BaseURL:           DefaultISAMSBaseURL,
+			PageSize:          DefaultISAMSPageSize,
+			RequestsPerSecond: 5,
+			PhotoConcurrency:  DefaultPhotoConcurrency,
+			UploadAttempts:    DefaultPhotoUploadAttempts,
+		},
+		Kissflow: KissflowConfig{
+			BaseURL:               "https://alice-smith.kissflow.com",
+			AccountID:             "AcflcLIlo4aq",
+			PageSize:              KissflowMaxPageSize,
+			BatchSize:             DefaultKissflowBatchSize,
+			UserMasterDataset:     DefaultUserMasterDataset,
+			StaffDataset:          "Employee_Master_01",
+			StaffView:             "Active_Employees_Basic_Details",
+			FamilyContactsDataset: "iSAMS_Family_ASIS_EDU_MY_Contacts",
+		},
+		Sheets: SheetsConfig{
+			SpreadsheetID:  "10hEhyN2-xeDT0b193h236u5lTbjHg5F7CuxjQN7IagA",
+			ServiceAccount: "api.json",
+			StudentsTab:    "Temp",
+			StaffTab:       "Staff",
+			ParentsTab:     "Parents",
+			BatchSize:      50,
+		},
+		Files: FilesConfig{
+			WorkspaceRoot:  ".",
+			CardCSV:        "P1 User July.csv",
+			KissflowExport: "Kissflow_export.csv",
+			OthersCSV:      "P1_OTHERS.csv",
+			FamilyReport:   "id_family_and_j.csv",
+			LargePhotoList: "students_large_photos.txt",
+			TAMSWorkbook:   "TAMS EP Students (1).xlsm",
+			Roster:         "trans/27August.xlsx",
+		},
+		Photos: PhotosConfig{
+			CacheDir:         "photo_cache",
+			CacheMaxAgeDays:  30,
+			BackupDir:        "photo_backups",
+			MaxBytes:         KissflowPhotoBudget,
+			MinQuality:       DefaultMinQuality,
+			MaxQuality:       DefaultMaxQuality,
+			PreferredQuality: DefaultPreferredQuality,
+			MinWidth:         DefaultMinPhotoWidth,
+			MinHeight:        DefaultMinPhotoHeight,
+			MaxWidth:         DefaultMaxPhotoWidth,
+			MaxHeight:        DefaultMaxPhotoHeight,
+			Formats:          DefaultPhotoFormats,
+			Print: PrintConfig{
+				MaxWidth:  DefaultPrintMaxWidth,
+				MaxHeight: DefaultPrintMaxHeight,
+				Quality:   DefaultPrintQuality,
+			},
+		},
+		Guard: GuardConfig{
+			MaxDeletes:           DefaultMaxDeletes,
+			MaxDeletePercent:     DefaultMaxDeletePercent,
+			MaxSourceDropPercent: DefaultMaxSourceDropPercent,
+			StatePath:            DefaultSyncStatePath,
+			ReportDir:            ".",
+		},
+		Cards: CardsConfig{
+			Format: CardAsWritten,
+			Merge:  CardMergeFirst,
+		},
+		Retention: RetentionConfig{
+			Students: DefaultRetentionDays,
+			Staff:    DefaultRetentionDays,
+			Parents:  DefaultRetentionDays,
+			Families: DefaultRetentionDays,
+			Others:   DefaultRetentionDays,
+		},
+	}
+}
+
+type configFile struct {
+	Config         `yaml:",inline"`
+	DefaultProfile string               `yaml:"default_profile"`
+	Profiles       map[string]yaml.Node `yaml:"profiles"`
+}
+
+// LoadConfig reads the config file at path and applies the named profile and
+// environment overrides. A missing file is only an error when explicit is
+// set; otherwise the defaults are used. An empty profile falls back to
+// KLASS_PROFILE and then to the file's default_profile.
+func LoadConfig(path, profile string, explicit bool) (*Config, error) {
+	file := configFile{Config: *DefaultConfig()}
+
+	b, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := yaml.Unmarshal(b, &file); err != nil {
+			return nil, fmt.Errorf("parse config %s: %w", path, err)
+		}
+	case os.IsNotExist(err) && !explicit:
+		Debugf("config file %s not found, using defaults", path)
+	default:
+		return nil, fmt.Errorf("read config: %w", err)
+	}
+
+	if profile == "" {
+		profile = os.Getenv("KLASS_PROFILE")
+	}
+	if profile == "" {
+		profile = file.DefaultProfile
+	}
+	cfg := file.Config
+	if profile != "" {
+		node, ok := file.Profiles[profile]
+		if !ok {
+			return nil, fmt.Errorf("profile %q not found in %s", profile, path)
+		}
+		if err := node.Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("parse profile %q: %w", profile, err)
+		}
+		cfg.Profile = profile
+	}
+
+	if err := applyEnv(reflect.ValueOf(&cfg).Elem()); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// applyEnv walks the config struct and overwrites every field whose env tag
+// names a variable that is set.
+func applyEnv(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		sf := t.Field(i)
+		if sf.Type.Kind() == reflect.Struct {
+			if err := applyEnv(field); err != nil {
+				return err
+			}
+			continue
+		}
+		tag := sf.Tag.Get("env")
+		if tag == "" {
+			continue
+		}
+		for _, name := range strings.Split(tag, ",") {
+			value, ok := os.LookupEnv(name)
+			if !ok || value == "" {
+				continue
+			}
+			if err := setField(field, value); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported config field type %s", field.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported config field type %s", field.Kind())
+	}
+	return nil
+}
+
+// ISAMSClient builds an iSAMS client from the config.
+func (c *Config) ISAMSClient() (*ISAMSClient, error) {
+	if c.ISAMS.APIKeyURL == "" {
+		return nil, fmt.Errorf("isams.api_key_url is not set (config file or API_KEY_URL)")
+	}
+	client := NewISAMSClient(c.ISAMS.BaseURL, c.ISAMS.APIKeyURL)
+	if c.ISAMS.PageSize > 0 {
+		client.PageSize = c.ISAMS.PageSize
+	}
+	if c.ISAMS.UploadAttempts > 0 {
+		client.UploadAttempts = c.ISAMS.UploadAttempts
+	}
+	client.SetRateLimit(c.ISAMS.RequestsPerSecond)
+	client.PhotoCache = c.PhotoCache()
+	client.Compressor = c.Compressor()
+	decoders, err := NewPhotoDecoders(c.Photos.Formats)
+	if err != nil {
+		return nil, fmt.Errorf("photos.formats: %w", err)
+	}
+	client.Decoders = decoders
+	return client, nil
+}
+
+// Compressor returns a photo compressor with the configured budget and
+// floors.
+func (c *Config) Compressor() *Compressor {
+	p := c.Photos
+	return &Compressor{
+		MaxBytes:         p.MaxBytes,
+		MinQuality:       p.MinQuality,
+		MaxQuality:       p.MaxQuality,
+		PreferredQuality: p.PreferredQuality,
+		MinWidth:         p.MinWidth,
+		MinHeight:        p.MinHeight,
+		MaxWidth:         p.MaxWidth,
+		MaxHeight:        p.MaxHeight,
+	}
+}
+
+// PhotoCache returns the configured photo cache, or nil when it is disabled.
+func (c *Config) PhotoCache() *PhotoCache {
+	if c.Photos.CacheDir == "" {
+		return nil
+	}
+	maxAge := time.Duration(c.Photos.CacheMaxAgeDays) * 24 * time.Hour
+	return NewPhotoCache(c.Files.Path(c.Photos.CacheDir), maxAge)
+}
+
+// KissflowClient builds a Kissflow client from the config.
+func (c *Config) KissflowClient() (*KissflowClient, error) {
+	k := c.Kissflow
+	if k.AccessKeyId == "" {
+		return nil, fmt.Errorf("kissflow.access_key_id is not set (config file or X_ACCESS_KEY_ID_VALUE)")
+	}
+	if k.AccessKeySecret == "" {
+		return nil, fmt.Errorf("kissflow.access_key_secret is not set (config file or X_ACCESS_KEY_SECRET_VALUE)")
+	}
+	client := NewKissflowClient(k.DatasetURL(), k.AccessKeyId, k.AccessKeySecret)
+	client.PageSize = k.PageSize
+	if k.BatchSize > 0 {
+		client.BatchSize = k.BatchSize
+	}
+	if k.UserMasterDataset != "" {
+		client.UserMasterDataset = k.UserMasterDataset
+	}
+	return client, nil
+}
+
+// CardSources builds the configured card sources.
+func (c *Config) CardSources() ([]CardSource, error) {
+	if len(c.Cards.Sources) == 0 {
+		return []CardSource{NewP1CardSource(c.Files.Path(c.Files.CardCSV))}, nil
+	}
+	var sources []CardSource
+	for i, sc := range c.Cards.Sources {
+		var src CardSource
+		switch sc.Type {
+		case CardSourceCSV, CardSourceXLSX:
+			if sc.Path == "" {
+				return nil, fmt.Errorf("cards.sources[%d]: path is not set", i)
+			}
+			columns := sc.Columns
+			if columns == (CardColumns{}) {
+				columns = P1CardColumns
+				sc.NoHeader = true
+			}
+			src = &TableCardSource{Path: c.Files.Path(sc.Path), Sheet: sc.Sheet, Columns: columns, NoHeader: sc.NoHeader}
+		case CardSourceTAMS:
+			path := sc.Path
+			if path == "" {
+				path = c.Files.TAMSWorkbook
+			}
+			if path == "" {
+				return nil, fmt.Errorf("cards.sources[%d]: neither path nor files.tams_workbook is set", i)
+			}
+			t := NewTAMSCardSource(c.Files.Path(path), sc.Sheet)
+			if sc.Columns != (CardColumns{}) {
+				t.Columns = sc.Columns
+			}
+			src = t
+		case CardSourceKissflow:
+			if sc.Dataset == "" {
+				return nil, fmt.Errorf("cards.sources[%d]: dataset is not set", i)
+			}
+			client, err := c.KissflowClient()
+			if err != nil {
+				return nil, err
+			}
+			src = &KissflowCardSource{Client: client, Dataset: sc.Dataset, View: sc.View, Columns: sc.Columns}
+		default:
+			return nil, fmt.Errorf("cards.sources[%d]: unknown type %q (known: %s, %s, %s, %s)", i, sc.Type, CardSourceCSV, CardSourceXLSX, CardSourceTAMS, CardSourceKissflow)
+		}
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
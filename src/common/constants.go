@@ -1,11 +0,0 @@
-package common
-
-const (
-	SPREADSHEET_ID        = "10hEhyN2-xeDT0b193h236u5lTbjHg5F7CuxjQN7IagA"
-	SHEET_NAME_STUDENTS   = "Temp"
-	SHEET_NAME_STAFF      = "Staff"
-	SHEET_NAME_PARENTS    = "Parents"
-	PAGE_SIZE             = 1000
-	USER_MASTER_BATCH_API = "https://alice-smith.kissflow.com/dataset/2/AcflcLIlo4aq/User_Master/batch"
-	BATCH_SIZE            = 500
-)
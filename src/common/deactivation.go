@@ -0,0 +1,94 @@
+package common
+
+import (
+	"time"
+)
+
+// User_Master fields written when a record is deactivated.
+const (
+	// StatusInactive is the User_Master Status of an inactive record.
+	StatusInactive = "2"
+	// FieldDeactivatedOn holds the date, as DeactivationDateLayout, a record
+	// was deactivated; purge counts the retention period from it.
+	FieldDeactivatedOn = "DeactivatedOn"
+	// FieldDeactivationReason says why the record was deactivated.
+	FieldDeactivationReason = "DeactivationReason"
+)
+
+// DeactivationDateLayout is how FieldDeactivatedOn is written.
+const DeactivationDateLayout = "2006-01-02"
+
+// Deactivation is what Reconcile writes into owned records that left the
+// source, instead of deleting them, so the card-to-person history survives.
+type Deactivation struct {
+	Reason string
+	At     time.Time
+}
+
+// payload returns the partial record that deactivates have.
+func (d *Deactivation) payload(id string, have map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"_id":                   id,
+		"Name":                  FieldString(have["Name"]),
+		"Status":                StatusInactive,
+		FieldDeactivatedOn:      d.At.Format(DeactivationDateLayout),
+		FieldDeactivationReason: d.Reason,
+	}
+}
+
+// IsDeactivated reports whether a User_Master record was deactivated by a
+// sync: inactive and dated. Records set inactive by their source, such as
+// families without a current student, carry no date.
+func IsDeactivated(rec map[string]interface{}) bool {
+	return FieldString(rec["Status"]) == StatusInactive && FieldString(rec[FieldDeactivatedOn]) != ""
+}
+
+// reactivated returns want with the deactivation fields cleared when have
+// was deactivated, so a returning leaver loses the stale date and reason.
+func reactivated(have, want map[string]interface{}) map[string]interface{} {
+	if FieldString(have[FieldDeactivatedOn]) == "" && FieldString(have[FieldDeactivationReason]) == "" {
+		return want
+	}
+	if _, ok := want[FieldDeactivatedOn]; ok {
+		return want
+	}
+	out := make(map[string]interface{}, len(want)+2)
+	for k, v := range want {
+		out[k] = v
+	}
+	out[FieldDeactivatedOn] = ""
+	out[FieldDeactivationReason] = ""
+	return out
+}
+
+// PurgeDeactivated returns a changeset deleting the owned records of a view
+// that were deactivated at least retention ago. Records whose date cannot
+// be read are kept and logged.
+func PurgeDeactivated(view string, current []map[string]interface{}, owns func(map[string]interface{}) bool, retention time.Duration, now time.Time) *Changeset {
+	cs := &Changeset{View: view}
+	cutoff := now.Add(-retention)
+	for _, rec := range current {
+		if owns != nil && !owns(rec) {
+			continue
+		}
+		cs.Owned++
+		id := FieldString(rec["_id"])
+		if id == "" || !IsDeactivated(rec) {
+			cs.Unchanged++
+			continue
+		}
+		on, err := time.ParseInLocation(DeactivationDateLayout, FieldString(rec[FieldDeactivatedOn]), now.Location())
+		if err != nil {
+			Warnf("purge %s: keeping %s, unreadable %s: %v", view, id, FieldDeactivatedOn, err)
+			cs.Unchanged++
+			continue
+		}
+		if on.After(cutoff) {
+			cs.Unchanged++
+			continue
+		}
+		cs.Deletes = append(cs.Deletes, RecordChange{ID: id, Before: rec})
+	}
+	sortChanges(cs.Deletes)
+	return cs
+}
@@ -0,0 +1,140 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	"golang.org/x/image/webp"
+)
+
+// DefaultPhotoFormats are the photo formats decoded when none are configured.
+var DefaultPhotoFormats = []string{"jpeg", "png", "gif", "bmp", "tiff", "webp"}
+
+// UnsupportedFormatError is returned for photos whose format was recognised
+// but is not enabled, or not recognised at all (Format is then empty).
+type UnsupportedFormatError struct {
+	Format string
+	Head   []byte
+}
+
+func (e *UnsupportedFormatError) Error() string {
+	if e.Format == "" {
+		return fmt.Sprintf("unrecognised photo format, first bytes: % x", e.Head)
+	}
+	return fmt.Sprintf("photo format %s is not enabled", e.Format)
+}
+
+type photoFormat struct {
+	sniff  func(b []byte) bool
+	decode func(r io.Reader) (image.Image, error)
+}
+
+// photoFormats maps every format name the registry knows to its magic-byte
+// test and decoder.
+var photoFormats = map[string]photoFormat{
+	"jpeg": {
+		sniff:  func(b []byte) bool { return bytes.HasPrefix(b, []byte{0xFF, 0xD8, 0xFF}) },
+		decode: jpeg.Decode,
+	},
+	"png": {
+		sniff:  func(b []byte) bool { return bytes.HasPrefix(b, []byte("\x89PNG\r\n\x1a\n")) },
+		decode: png.Decode,
+	},
+	"gif": {
+		sniff: func(b []byte) bool {
+			return bytes.HasPrefix(b, []byte("GIF87a")) || bytes.HasPrefix(b, []byte("GIF89a"))
+		},
+		decode: gif.Decode,
+	},
+	"bmp": {
+		sniff:  func(b []byte) bool { return bytes.HasPrefix(b, []byte("BM")) && len(b) > 14 },
+		decode: bmp.Decode,
+	},
+	"tiff": {
+		sniff: func(b []byte) bool {
+			return bytes.HasPrefix(b, []byte("II*\x00")) || bytes.HasPrefix(b, []byte("MM\x00*"))
+		},
+		decode: tiff.Decode,
+	},
+	"webp": {
+		sniff:  func(b []byte) bool { return len(b) >= 12 && string(b[:4]) == "RIFF" && string(b[8:12]) == "WEBP" },
+		decode: webp.Decode,
+	},
+}
+
+// SniffPhotoFormat names the format of b from its magic bytes, or returns ""
+// when no known format matches. The Content-Type iSAMS sends is not trusted.
+func SniffPhotoFormat(b []byte) string {
+	for _, name := range DefaultPhotoFormats {
+		if photoFormats[name].sniff(b) {
+			return name
+		}
+	}
+	return ""
+}
+
+// PhotoDecoders decodes the enabled subset of the known photo formats.
+type PhotoDecoders struct {
+	formats []string
+}
+
+// NewPhotoDecoders enables the named formats. Names are matched case
+// insensitively and "jpg"/"tif" are accepted as aliases. An empty list
+// enables DefaultPhotoFormats.
+func NewPhotoDecoders(formats []string) (*PhotoDecoders, error) {
+	if len(formats) == 0 {
+		formats = DefaultPhotoFormats
+	}
+	d := &PhotoDecoders{}
+	for _, f := range formats {
+		name := strings.ToLower(strings.TrimSpace(f))
+		switch name {
+		case "jpg":
+			name = "jpeg"
+		case "tif":
+			name = "tiff"
+		}
+		if _, ok := photoFormats[name]; !ok {
+			return nil, fmt.Errorf("unknown photo format %q (known: %s)", f, strings.Join(DefaultPhotoFormats, ", "))
+		}
+		d.formats = append(d.formats, name)
+	}
+	return d, nil
+}
+
+// String lists the enabled formats.
+func (d *PhotoDecoders) String() string {
+	return strings.Join(d.formats, ",")
+}
+
+func (d *PhotoDecoders) enabled(format string) bool {
+	for _, f := range d.formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Decode sniffs the format of b, decodes it if that format is enabled, and
+// normalizes the result with NormalizePhoto. It returns the format name
+// alongside the image.
+func (d *PhotoDecoders) Decode(b []byte) (image.Image, string, error) {
+	format := SniffPhotoFormat(b)
+	if format == "" || !d.enabled(format) {
+		return nil, format, &UnsupportedFormatError{Format: format, Head: b[:min(16, len(b))]}
+	}
+	img, err := photoFormats[format].decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, format, fmt.Errorf("decode %s: %w", format, err)
+	}
+	return NormalizePhoto(img, JPEGOrientation(b)), format, nil
+}
@@ -0,0 +1,163 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultMaxDeletes is the most records one sync may delete unforced.
+	DefaultMaxDeletes = 50
+	// DefaultMaxDeletePercent is the largest share of a view one sync may
+	// delete unforced.
+	DefaultMaxDeletePercent = 10.0
+	// DefaultMaxSourceDropPercent is how far the source record count may fall
+	// compared with the last successful run.
+	DefaultMaxSourceDropPercent = 20.0
+	// DefaultSyncStatePath stores the source counts of successful runs.
+	DefaultSyncStatePath = "sync_state.json"
+)
+
+// DeletionGuard stops a sync from deleting or deactivating most of
+// User_Master because an upstream API returned an empty or truncated list.
+// Deactivations count against the same limits as deletions. Any limit set to
+// zero or less is disabled.
+type DeletionGuard struct {
+	// Command names the sync in the state file and abort reports.
+	Command              string
+	MaxDeletes           int
+	MaxDeletePercent     float64
+	MaxSourceDropPercent float64
+	// Force lets the deletions through regardless of the limits.
+	Force     bool
+	StatePath string
+	ReportDir string
+}
+
+// NewDeletionGuard returns a guard for command with the configured limits.
+func NewDeletionGuard(command string, cfg GuardConfig, force bool) *DeletionGuard {
+	return &DeletionGuard{
+		Command:              command,
+		MaxDeletes:           cfg.MaxDeletes,
+		MaxDeletePercent:     cfg.MaxDeletePercent,
+		MaxSourceDropPercent: cfg.MaxSourceDropPercent,
+		Force:                force,
+		StatePath:            cfg.StatePath,
+		ReportDir:            cfg.ReportDir,
+	}
+}
+
+type syncRun struct {
+	SourceCount int       `json:"source_count"`
+	Deleted     int       `json:"deleted"`
+	At          time.Time `json:"at"`
+}
+
+// Check returns an error, and writes an abort report, when cs deletes more
+// than the limits allow or the source shrank sharply since the last run.
+func (g *DeletionGuard) Check(cs *Changeset) error {
+	var reasons []string
+	deletes := cs.Removals()
+
+	if g.MaxDeletes > 0 && deletes > g.MaxDeletes {
+		reasons = append(reasons, fmt.Sprintf("%d deletions and deactivations exceeds the limit of %d", deletes, g.MaxDeletes))
+	}
+	if g.MaxDeletePercent > 0 && cs.Owned > 0 {
+		pct := float64(deletes) * 100 / float64(cs.Owned)
+		if pct > g.MaxDeletePercent {
+			reasons = append(reasons, fmt.Sprintf("%d of %d records (%.1f%%) would be deleted or deactivated, limit is %.1f%%", deletes, cs.Owned, pct, g.MaxDeletePercent))
+		}
+	}
+	last, ok, err := g.lastRun()
+	if err != nil {
+		Warnf("could not read sync state: %v", err)
+	}
+	if ok && g.MaxSourceDropPercent > 0 && last.SourceCount > 0 {
+		drop := float64(last.SourceCount-cs.Desired) * 100 / float64(last.SourceCount)
+		if drop > g.MaxSourceDropPercent {
+			reasons = append(reasons, fmt.Sprintf("source returned %d records, down %.1f%% from %d on %s, limit is %.1f%%",
+				cs.Desired, drop, last.SourceCount, last.At.Format(time.RFC3339), g.MaxSourceDropPercent))
+		}
+	}
+
+	if len(reasons) == 0 {
+		return nil
+	}
+	if g.Force {
+		Warnf("deletion guard overridden with -force-deletions: %s", strings.Join(reasons, "; "))
+		return nil
+	}
+
+	reportPath, err := g.writeReport(cs, reasons)
+	if err != nil {
+		Warnf("could not write deletion guard report: %v", err)
+	}
+	return fmt.Errorf("deletion guard aborted %s sync of User_Master %s: %s (report: %s; re-run with -force-deletions to override)",
+		g.Command, cs.View, strings.Join(reasons, "; "), reportPath)
+}
+
+// RecordSuccess remembers the source count of a run that was applied.
+func (g *DeletionGuard) RecordSuccess(cs *Changeset) error {
+	state, err := g.readState()
+	if err != nil {
+		return err
+	}
+	state[g.Command] = syncRun{SourceCount: cs.Desired, Deleted: cs.Removals(), At: time.Now().UTC()}
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(g.StatePath, b, 0644)
+}
+
+func (g *DeletionGuard) lastRun() (syncRun, bool, error) {
+	state, err := g.readState()
+	if err != nil {
+		return syncRun{}, false, err
+	}
+	run, ok := state[g.Command]
+	return run, ok, nil
+}
+
+func (g *DeletionGuard) readState() (map[string]syncRun, error) {
+	state := make(map[string]syncRun)
+	b, err := os.ReadFile(g.StatePath)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", g.StatePath, err)
+	}
+	return state, nil
+}
+
+func (g *DeletionGuard) writeReport(cs *Changeset, reasons []string) (string, error) {
+	now := time.Now()
+	path := filepath.Join(g.ReportDir, fmt.Sprintf("deletion_guard_%s_%s.txt", g.Command, now.Format("20060102_150405")))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Deletion guard aborted the %s sync at %s\n\n", g.Command, now.Format(time.RFC3339))
+	fmt.Fprintf(&b, "View:              User_Master %s\n", cs.View)
+	fmt.Fprintf(&b, "Records in view:   %d\n", cs.Owned)
+	fmt.Fprintf(&b, "Records in source: %d\n", cs.Desired)
+	fmt.Fprintf(&b, "Creates/updates:   %d/%d\n", len(cs.Creates), len(cs.Updates))
+	fmt.Fprintf(&b, "Deactivates:       %d\n", len(cs.Deactivates))
+	fmt.Fprintf(&b, "Deletes:           %d\n\n", len(cs.Deletes))
+	b.WriteString("Reasons:\n")
+	for _, r := range reasons {
+		fmt.Fprintf(&b, "  - %s\n", r)
+	}
+	b.WriteString("\nNothing was changed. Check the source data; if the deletions are expected, re-run with -force-deletions.\n\n")
+	b.WriteString("Records that would have been deleted or deactivated:\n")
+	for _, rc := range append(append([]RecordChange{}, cs.Deactivates...), cs.Deletes...) {
+		fmt.Fprintf(&b, "  %s\t%s\t%s\n", rc.ID, FieldString(rc.Before["Name"]), FieldString(rc.Before["Name_1"]))
+	}
+	return path, os.WriteFile(path, []byte(b.String()), 0644)
+}
@@ -0,0 +1,87 @@
+package common
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDeletionGuardCheck(t *testing.T) {
+	deletes := func(n int) []RecordChange {
+		out := make([]RecordChange, n)
+		for i := range out {
+			out[i] = RecordChange{ID: string(rune('a' + i))}
+		}
+		return out
+	}
+	tests := []struct {
+		name    string
+		guard   DeletionGuard
+		cs      Changeset
+		blocked string
+	}{
+		{
+			name:  "within limits",
+			guard: DeletionGuard{MaxDeletes: 5, MaxDeletePercent: 50},
+			cs:    Changeset{Deletes: deletes(2), Owned: 10, Desired: 8},
+		},
+		{
+			name:    "too many deletes",
+			guard:   DeletionGuard{MaxDeletes: 1},
+			cs:      Changeset{Deletes: deletes(2), Owned: 100},
+			blocked: "2 deletions and deactivations exceeds the limit of 1",
+		},
+		{
+			name:    "deactivations count as deletes",
+			guard:   DeletionGuard{MaxDeletes: 2},
+			cs:      Changeset{Deletes: deletes(1), Deactivates: deletes(2), Owned: 100},
+			blocked: "3 deletions and deactivations",
+		},
+		{
+			name:    "too large a share of the view",
+			guard:   DeletionGuard{MaxDeletePercent: 10},
+			cs:      Changeset{Deletes: deletes(3), Owned: 10},
+			blocked: "3 of 10 records (30.0%)",
+		},
+		{
+			name:  "zero limits are disabled",
+			guard: DeletionGuard{},
+			cs:    Changeset{Deletes: deletes(9), Owned: 9},
+		},
+		{
+			name:  "force overrides",
+			guard: DeletionGuard{MaxDeletes: 1, Force: true},
+			cs:    Changeset{Deletes: deletes(5), Owned: 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			g := tt.guard
+			g.Command = "test"
+			g.StatePath = filepath.Join(dir, "state.json")
+			g.ReportDir = dir
+			err := g.Check(&tt.cs)
+			switch {
+			case tt.blocked == "" && err != nil:
+				t.Errorf("Check = %v, want nil", err)
+			case tt.blocked != "" && (err == nil || !strings.Contains(err.Error(), tt.blocked)):
+				t.Errorf("Check = %v, want an error containing %q", err, tt.blocked)
+			}
+		})
+	}
+}
+
+func TestDeletionGuardSourceDrop(t *testing.T) {
+	dir := t.TempDir()
+	g := &DeletionGuard{Command: "students", MaxSourceDropPercent: 20, StatePath: filepath.Join(dir, "state.json"), ReportDir: dir}
+	if err := g.RecordSuccess(&Changeset{Desired: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Check(&Changeset{Desired: 85}); err != nil {
+		t.Errorf("15%% drop: Check = %v, want nil", err)
+	}
+	if err := g.Check(&Changeset{Desired: 50}); err == nil || !strings.Contains(err.Error(), "down 50.0% from 100") {
+		t.Errorf("50%% drop: Check = %v, want a source drop error", err)
+	}
+}
@@ -0,0 +1,33 @@
+package common
+
+import (
+	"image"
+	"math/bits"
+
+	"golang.org/x/image/draw"
+)
+
+// DHash returns the 64-bit difference hash of img: the image is shrunk to
+// 9x8 greyscale and each bit records whether a pixel is brighter than its
+// right-hand neighbour. Re-encoded, resized or lightly edited copies of a
+// photo keep almost the same hash.
+func DHash(img image.Image) uint64 {
+	small := image.NewGray(image.Rect(0, 0, 9, 8))
+	draw.ApproxBiLinear.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+	var h uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			h <<= 1
+			if small.GrayAt(x, y).Y > small.GrayAt(x+1, y).Y {
+				h |= 1
+			}
+		}
+	}
+	return h
+}
+
+// HashDistance is the number of differing bits between two hashes; 0 means
+// identical photos, and up to about 10 near-identical ones.
+func HashDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
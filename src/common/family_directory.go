@@ -0,0 +1,217 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kissflow student columns (and dataset fields) the family directory needs.
+const (
+	familyColMembershipNo = "parentMembershipNo"
+	familyColEnrollment   = "enrollmentStatus"
+)
+
+// EnrollmentFormer is the enrollment status of a student who has left.
+const EnrollmentFormer = "Former"
+
+// FamilyChild is one student of a family.
+type FamilyChild struct {
+	// Line is the row in the export, or the position in the dataset
+	// listing.
+	Line             int
+	EnrollmentStatus string
+	// Fields holds every column of the row by header, or every field of
+	// the dataset record.
+	Fields map[string]string
+}
+
+// Family is a membership and all its children.
+type Family struct {
+	// MembershipNo is the membership number as the export writes it,
+	// letters included.
+	MembershipNo string
+	Children     []FamilyChild
+}
+
+// Active reports whether any child of the family is not a former student.
+func (f *Family) Active() bool {
+	for _, c := range f.Children {
+		if !strings.EqualFold(c.EnrollmentStatus, EnrollmentFormer) {
+			return true
+		}
+	}
+	return false
+}
+
+// FamilyDirectory indexes the families of the Kissflow students by the
+// digits of their membership number.
+type FamilyDirectory struct {
+	// Source names where the students were read from: the export path or
+	// the Kissflow dataset.
+	Source string
+	// ProducedAt is when the data was produced: the export's modification
+	// time, or when the dataset was listed.
+	ProducedAt time.Time
+	families   map[string]*Family
+	// skipped counts students without a membership number.
+	skipped int
+}
+
+func newFamilyDirectory(source string, producedAt time.Time) *FamilyDirectory {
+	return &FamilyDirectory{Source: source, ProducedAt: producedAt, families: map[string]*Family{}}
+}
+
+// add files a student under their membership number. When students of one
+// family write the number differently, the first spelling is kept.
+func (d *FamilyDirectory) add(membershipNo string, child FamilyChild) {
+	key := membershipDigits(membershipNo)
+	if key == "" {
+		d.skipped++
+		return
+	}
+	f, ok := d.families[key]
+	if !ok {
+		f = &Family{MembershipNo: membershipNo}
+		d.families[key] = f
+	} else if f.MembershipNo != membershipNo {
+		Debugf("family %s is also written %s (%s line %d)", f.MembershipNo, membershipNo, d.Source, child.Line)
+	}
+	f.Children = append(f.Children, child)
+}
+
+func (d *FamilyDirectory) logSkipped() {
+	if d.skipped > 0 {
+		Debugf("%s: skipped %d students without a membership number", d.Source, d.skipped)
+	}
+}
+
+// LoadFamilyDirectory reads the Kissflow student export once. The
+// parentMembershipNo and enrollmentStatus columns are required; rows whose
+// membership number has no digits are skipped.
+func LoadFamilyDirectory(path string) (*FamilyDirectory, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("Kissflow export: %w", err)
+	}
+	rows, err := readCSVRows(path)
+	if err != nil {
+		return nil, fmt.Errorf("Kissflow export %s: %w", path, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("Kissflow export %s is empty", path)
+	}
+	header := rows[0]
+	col := map[string]int{}
+	for i, h := range header {
+		col[strings.TrimSpace(h)] = i
+	}
+	var missing []string
+	for _, name := range []string{familyColMembershipNo, familyColEnrollment} {
+		if _, ok := col[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("Kissflow export %s has no %s column", path, strings.Join(missing, " or "))
+	}
+
+	d := newFamilyDirectory(path, info.ModTime())
+	for i, rec := range rows[1:] {
+		fields := make(map[string]string, len(header))
+		for j, h := range header {
+			fields[strings.TrimSpace(h)] = cell(rec, j)
+		}
+		d.add(cell(rec, col[familyColMembershipNo]), FamilyChild{
+			Line:             i + 2,
+			EnrollmentStatus: cell(rec, col[familyColEnrollment]),
+			Fields:           fields,
+		})
+	}
+	d.logSkipped()
+	return d, nil
+}
+
+// FetchFamilyDirectory lists the students straight from a Kissflow dataset,
+// or one of its views when view is set. Kissflow leaves empty fields out of
+// a record, so the parentMembershipNo and enrollmentStatus fields only need
+// to appear in some record; an empty listing is an error, since it would
+// make every family inactive.
+func FetchFamilyDirectory(c *KissflowClient, dataset, view string) (*FamilyDirectory, error) {
+	source := "kissflow:" + dataset
+	var records []map[string]interface{}
+	var err error
+	if view != "" {
+		source += "/" + view
+		records, err = ListView[map[string]interface{}](c, dataset, view)
+	} else {
+		records, err = ListDataset[map[string]interface{}](c, dataset)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s has no students", source)
+	}
+
+	d := newFamilyDirectory(source, time.Now())
+	seen := map[string]bool{}
+	for i, rec := range records {
+		fields := make(map[string]string, len(rec))
+		for k, v := range rec {
+			fields[k] = FieldString(v)
+			seen[k] = true
+		}
+		d.add(fields[familyColMembershipNo], FamilyChild{
+			Line:             i + 1,
+			EnrollmentStatus: fields[familyColEnrollment],
+			Fields:           fields,
+		})
+	}
+	var missing []string
+	for _, name := range []string{familyColMembershipNo, familyColEnrollment} {
+		if !seen[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%s has no %s field", source, strings.Join(missing, " or "))
+	}
+	d.logSkipped()
+	return d, nil
+}
+
+// Lookup returns the family whose membership number has the same digits as
+// membershipNo.
+func (d *FamilyDirectory) Lookup(membershipNo string) (*Family, bool) {
+	f, ok := d.families[membershipDigits(membershipNo)]
+	return f, ok
+}
+
+// Len is the number of families.
+func (d *FamilyDirectory) Len() int {
+	return len(d.families)
+}
+
+// Families returns every family ordered by membership number.
+func (d *FamilyDirectory) Families() []*Family {
+	families := make([]*Family, 0, len(d.families))
+	for _, f := range d.families {
+		families = append(families, f)
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].MembershipNo < families[j].MembershipNo })
+	return families
+}
+
+// membershipDigits keeps only the digits of a membership number.
+func membershipDigits(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,27 @@
+package common
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// sharedHTTPClient is the single pooled client used for every outbound API
+// call. Reusing it keeps connections alive between requests and makes sure
+// nothing can hang forever on a stalled server.
+var sharedHTTPClient = &http.Client{
+	Timeout: 2 * time.Minute,
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   15 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   16,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   15 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+		ExpectContinueTimeout: time.Second,
+	},
+}
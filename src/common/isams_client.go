@@ -0,0 +1,195 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultISAMSBaseURL is the production iSAMS REST API root.
+	DefaultISAMSBaseURL = "https://alice-smith.isamshosting.cloud/Main/api"
+	// DefaultISAMSPageSize is the page size used for student listings.
+	DefaultISAMSPageSize = 1000
+)
+
+type bearerTokenResponse struct {
+	BearerToken string `json:"bearer_token"`
+}
+
+// ISAMSClient talks to the iSAMS REST API. It owns the base URL, the shared
+// HTTP client and the bearer token obtained from the API key URL. When the
+// API answers 401 the token is refreshed and the request is retried once.
+type ISAMSClient struct {
+	BaseURL   string
+	APIKeyURL string
+	PageSize  int
+
+	// PhotoCache, when set, lets FetchStudentPhoto skip photos that have
+	// not changed since they were last processed.
+	PhotoCache *PhotoCache
+	// Compressor shrinks photos for Kissflow; nil uses NewCompressor.
+	Compressor *Compressor
+	// Decoders lists the photo formats accepted; nil accepts
+	// DefaultPhotoFormats.
+	Decoders *PhotoDecoders
+	// UploadAttempts is how many times a photo upload is tried until the
+	// current photo reads back as sent; 0 tries once.
+	UploadAttempts int
+
+	httpClient *http.Client
+	limiter    *rate.Limiter
+
+	mu     sync.Mutex
+	bearer string
+}
+
+// NewISAMSClient returns a client for the given API root and token URL.
+func NewISAMSClient(baseURL, apiKeyUrl string) *ISAMSClient {
+	return &ISAMSClient{
+		BaseURL:        strings.TrimRight(baseURL, "/"),
+		APIKeyURL:      apiKeyUrl,
+		PageSize:       DefaultISAMSPageSize,
+		UploadAttempts: DefaultPhotoUploadAttempts,
+		httpClient:     sharedHTTPClient,
+	}
+}
+
+func (c *ISAMSClient) compressor() *Compressor {
+	if c.Compressor == nil {
+		return NewCompressor()
+	}
+	return c.Compressor
+}
+
+func (c *ISAMSClient) decoders() *PhotoDecoders {
+	if c.Decoders == nil {
+		d, _ := NewPhotoDecoders(nil)
+		return d
+	}
+	return c.Decoders
+}
+
+// photoPipeline identifies the decoder and compressor settings, so cached
+// output made with other settings is not reused.
+func (c *ISAMSClient) photoPipeline() string {
+	return c.compressor().String() + " formats=" + c.decoders().String()
+}
+
+// SetRateLimit caps the client at requestsPerSecond requests, shared by every
+// goroutine using it. Zero or less removes the cap.
+func (c *ISAMSClient) SetRateLimit(requestsPerSecond float64) {
+	if requestsPerSecond <= 0 {
+		c.limiter = nil
+		return
+	}
+	burst := int(requestsPerSecond)
+	if burst < 1 {
+		burst = 1
+	}
+	c.limiter = rate.NewLimiter(rate.Limit(requestsPerSecond), burst)
+}
+
+// Bearer returns the current "Bearer <token>" header value, fetching a token
+// on first use.
+func (c *ISAMSClient) Bearer() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.bearer != "" {
+		return c.bearer, nil
+	}
+	return c.refreshLocked()
+}
+
+// refreshBearer discards the cached token unless another goroutine already
+// replaced it since stale was issued.
+func (c *ISAMSClient) refreshBearer(stale string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.bearer != "" && c.bearer != stale {
+		return c.bearer, nil
+	}
+	return c.refreshLocked()
+}
+
+func (c *ISAMSClient) refreshLocked() (string, error) {
+	resp, err := c.httpClient.Get(c.APIKeyURL)
+	if err != nil {
+		return "", fmt.Errorf("get bearer token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("get bearer token: status=%d, body=%s", resp.StatusCode, string(body))
+	}
+	var tokenResp bearerTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", fmt.Errorf("decode bearer token: %w", err)
+	}
+	if tokenResp.BearerToken == "" {
+		return "", fmt.Errorf("get bearer token: empty token in response")
+	}
+	c.bearer = "Bearer " + tokenResp.BearerToken
+	return c.bearer, nil
+}
+
+// do sends an authenticated request to path (relative to BaseURL). On a 401
+// the token is refreshed and the request is sent a second time.
+func (c *ISAMSClient) do(method, path string, body []byte, contentType string) (*http.Response, error) {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return c.doHeader(method, path, body, header)
+}
+
+// doHeader is do with arbitrary request headers.
+func (c *ISAMSClient) doHeader(method, path string, body []byte, header http.Header) (*http.Response, error) {
+	bearer, err := c.Bearer()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.send(method, path, body, header, bearer)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	bearer, err = c.refreshBearer(bearer)
+	if err != nil {
+		return nil, err
+	}
+	return c.send(method, path, body, header, bearer)
+}
+
+func (c *ISAMSClient) send(method, path string, body []byte, header http.Header, bearer string) (*http.Response, error) {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.BaseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Authorization", bearer)
+	return c.httpClient.Do(req)
+}
@@ -0,0 +1,132 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// KissflowMaxPageSize is the largest page_size the list calls request.
+	// The scripts this client replaced always asked for 999; a larger page
+	// has not been checked against the Kissflow API limits.
+	KissflowMaxPageSize = 999
+	// DefaultKissflowBatchSize is the number of records sent per batch upsert.
+	DefaultKissflowBatchSize = 500
+	// DefaultUserMasterDataset is the dataset the syncs reconcile.
+	DefaultUserMasterDataset = "User_Master"
+)
+
+// KissflowClient talks to the Kissflow dataset API. It sets the access key
+// headers on every request and knows how to page through dataset and view
+// listings.
+type KissflowClient struct {
+	BaseURL         string
+	AccessKeyId     string
+	AccessKeySecret string
+	PageSize        int
+	// BatchSize is the number of records sent per User_Master batch upsert.
+	BatchSize int
+	// UserMasterDataset is the dataset the syncs reconcile.
+	UserMasterDataset string
+
+	httpClient *http.Client
+}
+
+// NewKissflowClient returns a client for the given dataset root and keys.
+func NewKissflowClient(baseURL, accessKeyId, accessKeySecret string) *KissflowClient {
+	return &KissflowClient{
+		BaseURL:           strings.TrimRight(baseURL, "/"),
+		AccessKeyId:       accessKeyId,
+		AccessKeySecret:   accessKeySecret,
+		PageSize:          KissflowMaxPageSize,
+		BatchSize:         DefaultKissflowBatchSize,
+		UserMasterDataset: DefaultUserMasterDataset,
+		httpClient:        sharedHTTPClient,
+	}
+}
+
+// do sends an authenticated request to path (relative to BaseURL). A non-nil
+// body is encoded as JSON. Responses outside 2xx are returned as errors.
+func (c *KissflowClient) do(method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		jsonPayload, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal payload: %w", err)
+		}
+		reader = bytes.NewReader(jsonPayload)
+	}
+	req, err := http.NewRequest(method, c.BaseURL+"/"+strings.TrimLeft(path, "/"), reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Access-Key-Id", c.AccessKeyId)
+	req.Header.Set("X-Access-Key-Secret", c.AccessKeySecret)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s %s: status=%d, body=%s", method, path, resp.StatusCode, string(b))
+	}
+	return resp, nil
+}
+
+func (c *KissflowClient) pageSize() int {
+	if c.PageSize <= 0 || c.PageSize > KissflowMaxPageSize {
+		return KissflowMaxPageSize
+	}
+	return c.PageSize
+}
+
+// ListDataset returns every record of dataset decoded into T.
+func ListDataset[T any](c *KissflowClient, dataset string) ([]T, error) {
+	return listPages[T](c, dataset+"/list")
+}
+
+// ListView returns every record of the named dataset view decoded into T.
+func ListView[T any](c *KissflowClient, dataset, view string) ([]T, error) {
+	return listPages[T](c, fmt.Sprintf("%s/view/%s/list", dataset, view))
+}
+
+func listPages[T any](c *KissflowClient, path string) ([]T, error) {
+	pageSize := c.pageSize()
+	all := []T{}
+	page := 1
+	for {
+		var result struct {
+			Data []T `json:"Data"`
+		}
+		if err := c.getJSON(fmt.Sprintf("%s?page_number=%d&page_size=%d", path, page, pageSize), &result); err != nil {
+			return nil, err
+		}
+		all = append(all, result.Data...)
+		if len(result.Data) < pageSize {
+			break
+		}
+		page++
+	}
+	return all, nil
+}
+
+func (c *KissflowClient) getJSON(path string, out interface{}) error {
+	resp, err := c.do("GET", path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return nil
+}
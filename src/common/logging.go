@@ -0,0 +1,58 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// Log levels accepted by SetLogLevel.
+const (
+	LogDebug = "debug"
+	LogInfo  = "info"
+	LogWarn  = "warn"
+	LogError = "error"
+)
+
+var (
+	logLevel   = LogInfo
+	warnLogger = log.New(os.Stderr, "", log.LstdFlags)
+)
+
+// SetLogLevel sets how chatty the tools are. Plain log.Printf calls count as
+// info and are silenced at warn and error; Warnf and Errorf always go to
+// stderr at or below their level.
+func SetLogLevel(level string) error {
+	level = strings.ToLower(strings.TrimSpace(level))
+	switch level {
+	case LogDebug, LogInfo:
+		log.SetOutput(os.Stderr)
+	case LogWarn, LogError:
+		log.SetOutput(io.Discard)
+	default:
+		return fmt.Errorf("unknown log level %q (want debug, info, warn or error)", level)
+	}
+	logLevel = level
+	return nil
+}
+
+// Debugf logs only when the level is debug.
+func Debugf(format string, args ...interface{}) {
+	if logLevel == LogDebug {
+		log.Printf(format, args...)
+	}
+}
+
+// Warnf logs a warning unless the level is error.
+func Warnf(format string, args ...interface{}) {
+	if logLevel != LogError {
+		warnLogger.Printf("WARNING: "+format, args...)
+	}
+}
+
+// Errorf logs an error at every level.
+func Errorf(format string, args ...interface{}) {
+	warnLogger.Printf("ERROR: "+format, args...)
+}
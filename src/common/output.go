@@ -0,0 +1,50 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Output formats accepted by the -output flag.
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// ValidOutput reports whether format is a supported -output value.
+func ValidOutput(format string) bool {
+	return format == OutputText || format == OutputJSON
+}
+
+// WriteJSON writes v as indented JSON.
+func WriteJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// PrintChangeset writes cs in the requested format. The text form lists every
+// record with the fields that change.
+func PrintChangeset(w io.Writer, cs *Changeset, format string) error {
+	if format == OutputJSON {
+		return WriteJSON(w, cs)
+	}
+	fmt.Fprintln(w, cs.Summary())
+	for _, rc := range cs.Creates {
+		fmt.Fprintf(w, "  + %s %s\n", rc.ID, FieldString(rc.After["Name_1"]))
+	}
+	for _, rc := range cs.Updates {
+		fmt.Fprintf(w, "  ~ %s %s\n", rc.ID, FieldString(rc.After["Name_1"]))
+		for _, f := range rc.Fields {
+			fmt.Fprintf(w, "      %s: %q -> %q\n", f.Field, f.Before, f.After)
+		}
+	}
+	for _, rc := range cs.Deactivates {
+		fmt.Fprintf(w, "  x %s %s (%s)\n", rc.ID, FieldString(rc.Before["Name_1"]), FieldString(rc.After[FieldDeactivationReason]))
+	}
+	for _, rc := range cs.Deletes {
+		fmt.Fprintf(w, "  - %s %s\n", rc.ID, FieldString(rc.Before["Name_1"]))
+	}
+	return nil
+}
@@ -1,121 +1,195 @@
 package common
 
 import (
-	"bytes"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"image"
-	"image/jpeg"
-	"image/png"
 	"io"
 	"log"
 	"net/http"
 	"os"
-	"strings"
-
-	"golang.org/x/image/draw"
 )
 
 type Photo struct {
 	Base64Data     string
 	OriginalSize   int
 	CompressedSize int
-	Status         string
+	// Quality, Width and Height are what the compressor chose.
+	Quality int
+	Width   int
+	Height  int
+	// DHash is the perceptual hash of the decoded photo; it is only
+	// meaningful when Decoded reports true.
+	DHash uint64
+	// SHA256 is the hex SHA-256 of the original bytes iSAMS served, so a
+	// changed photo can be told apart even though image_1 cannot be
+	// compared.
+	SHA256 string
+	Status string
 }
 
 func (p *Photo) IsValid() bool {
 	return p.Status == "ok" && p.Base64Data != ""
 }
 
-func FetchStudentPhoto(schoolId, bearer string) (*Photo, error) {
-	url := fmt.Sprintf("https://alice-smith.isamshosting.cloud/Main/api/students/%s/photos/current", schoolId)
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", bearer)
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// Decoded reports whether the original could be decoded, even if it could
+// not then be compressed.
+func (p *Photo) Decoded() bool {
+	return p.Status == "ok" || p.Status == ErrOverBudget.Error() || p.Status == "compression error"
+}
+
+// FetchStudentPhoto downloads the student's current photo and compresses it
+// for the Kissflow image_1 field. With a PhotoCache the download is made
+// conditional on the cached validators, and the cached copy is reused when
+// iSAMS reports no change or serves the same bytes.
+func (c *ISAMSClient) FetchStudentPhoto(schoolId string) (*Photo, error) {
+	return c.fetchStudentPhoto(schoolId, c.PhotoCache)
+}
+
+// fetchStudentPhoto is FetchStudentPhoto with the cache to use; nil skips
+// it.
+func (c *ISAMSClient) fetchStudentPhoto(schoolId string, cache *PhotoCache) (*Photo, error) {
+	var cached *PhotoCacheEntry
+	if cache != nil {
+		entry, err := cache.Get(schoolId)
+		if err != nil {
+			Warnf("ignoring photo cache for %s: %v", schoolId, err)
+		}
+		// A copy made with other settings is stale even when the original
+		// is not.
+		if entry != nil && entry.Pipeline == c.photoPipeline() {
+			cached = entry
+		}
+	}
+
+	header := http.Header{}
+	if cached != nil {
+		if cached.ETag != "" {
+			header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+	resp, err := c.doHeader("GET", fmt.Sprintf("/students/%s/photos/current", schoolId), nil, header)
 	if err != nil {
 		return &Photo{Status: "download error"}, err
 	}
 	defer resp.Body.Close()
 
-	contentType := resp.Header.Get("Content-Type")
-	if !strings.HasPrefix(contentType, "image/") {
-		log.Printf("schoolId %s: Unexpected Content-Type: %s", schoolId, contentType)
-		return &Photo{Status: "not image"}, nil
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		photo, err := c.cachedPhoto(cached)
+		if err == nil {
+			return photo, nil
+		}
+		Warnf("photo cache for %s unreadable, downloading again without it: %v", schoolId, err)
+		if err := cache.Remove(schoolId); err != nil {
+			Warnf("could not remove photo cache for %s: %v", schoolId, err)
+		}
+		return c.fetchStudentPhoto(schoolId, nil)
+	}
+	// Error bodies are neither decoded nor cached.
+	if resp.StatusCode == http.StatusNotFound {
+		return &Photo{Status: "no photo"}, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &Photo{Status: "download error"}, fmt.Errorf("download failed: status=%d", resp.StatusCode)
 	}
 
+	// The Content-Type is only logged; processPhoto sniffs the bytes.
+	contentType := resp.Header.Get("Content-Type")
 	imgBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return &Photo{Status: "read error"}, err
 	}
 
-	photo := &Photo{
-		OriginalSize: len(imgBytes),
-		Status:       "processing",
+	if cache == nil {
+		return c.processPhoto(schoolId, contentType, imgBytes), nil
+	}
+	if cached != nil && cached.SHA256 == PhotoSHA256(imgBytes) {
+		cached.ETag = resp.Header.Get("ETag")
+		cached.LastModified = resp.Header.Get("Last-Modified")
+		if photo, err := c.cachedPhoto(cached); err == nil {
+			return photo, nil
+		}
+	}
+	photo := c.processPhoto(schoolId, contentType, imgBytes)
+	entry := &PhotoCacheEntry{
+		SchoolId:     schoolId,
+		ContentType:  contentType,
+		Pipeline:     c.photoPipeline(),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	if err := cache.Put(entry, imgBytes, photo); err != nil {
+		Warnf("could not cache photo for %s: %v", schoolId, err)
 	}
+	return photo, nil
+}
 
-	var img image.Image
-	img, decodeErr := jpeg.Decode(bytes.NewReader(imgBytes))
-	if decodeErr != nil {
-		img, decodeErr = png.Decode(bytes.NewReader(imgBytes))
+// DecodePhoto decodes raw photo bytes with the client's enabled formats and
+// returns them upright and opaque.
+func (c *ISAMSClient) DecodePhoto(b []byte) (image.Image, error) {
+	img, _, err := c.decoders().Decode(b)
+	return img, err
+}
+
+// CompressPhoto compresses a decoded photo with the client's compressor.
+func (c *ISAMSClient) CompressPhoto(img image.Image) (*CompressResult, error) {
+	return c.compressor().Compress(img)
+}
+
+// cachedPhoto returns the cached photo and marks the entry as still current.
+func (c *ISAMSClient) cachedPhoto(entry *PhotoCacheEntry) (*Photo, error) {
+	photo, err := c.PhotoCache.Photo(entry)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.PhotoCache.Touch(entry); err != nil {
+		Warnf("could not update photo cache for %s: %v", entry.SchoolId, err)
+	}
+	return photo, nil
+}
+
+// processPhoto decodes the raw photo bytes, turns them upright and opaque,
+// hashes them and compresses them into a JPEG within the client's byte
+// budget. Failures are reported through Photo.Status.
+func (c *ISAMSClient) processPhoto(schoolId, contentType string, imgBytes []byte) *Photo {
+	img, format, decodeErr := c.decoders().Decode(imgBytes)
+	var unsupported *UnsupportedFormatError
+	if errors.As(decodeErr, &unsupported) && unsupported.Format != "" {
+		log.Printf("schoolId %s: %v", schoolId, decodeErr)
+		return &Photo{Status: "unsupported format"}
 	}
 	if decodeErr != nil {
 		log.Printf("schoolId %s: decode error: %v, Content-Type: %s, first bytes: % x", schoolId, decodeErr, contentType, imgBytes[:min(16, len(imgBytes))])
 		filename := fmt.Sprintf("failed_photo_%s.bin", schoolId)
 		_ = os.WriteFile(filename, imgBytes, 0644)
-		return &Photo{Status: "decode error"}, nil
-	}
-
-	var buf bytes.Buffer
-	quality := 40
-	if photo.OriginalSize > 102400 {
-		quality = 40
-	}
-	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
-		return &Photo{Status: "compression error"}, nil
-	}
-
-	compressed := buf.Bytes()
-	photo.CompressedSize = len(compressed)
-
-	if photo.CompressedSize > 37500 {
-		// Resize to max 400x600 and try again
-		maxW, maxH := 400, 600
-		bounds := img.Bounds()
-		w, h := bounds.Dx(), bounds.Dy()
-		newW, newH := w, h
-		if w > maxW || h > maxH {
-			ratioW := float64(maxW) / float64(w)
-			ratioH := float64(maxH) / float64(h)
-			ratio := ratioW
-			if ratioH < ratioW {
-				ratio = ratioH
-			}
-			newW = int(float64(w) * ratio)
-			newH = int(float64(h) * ratio)
-			if newW < 1 {
-				newW = 1
-			}
-			if newH < 1 {
-				newH = 1
-			}
-		}
-		resized := image.NewRGBA(image.Rect(0, 0, newW, newH))
-		draw.CatmullRom.Scale(resized, resized.Bounds(), img, bounds, draw.Over, nil)
-		buf.Reset()
-		if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: quality}); err != nil {
-			return &Photo{Status: "resize/compression error"}, nil
-		}
-		compressed = buf.Bytes()
-		photo.CompressedSize = len(compressed)
-		if photo.CompressedSize > 37500 {
-			return &Photo{Status: "too large after resize"}, nil
-		}
+		return &Photo{Status: "decode error"}
 	}
 
-	photo.Base64Data = base64.StdEncoding.EncodeToString(compressed)
-	photo.Status = "ok"
-	return photo, nil
+	hash := DHash(img)
+	sum := PhotoSHA256(imgBytes)
+	res, err := c.compressor().Compress(img)
+	if errors.Is(err, ErrOverBudget) {
+		return &Photo{Status: ErrOverBudget.Error(), DHash: hash, SHA256: sum}
+	}
+	if err != nil {
+		return &Photo{Status: "compression error", DHash: hash, SHA256: sum}
+	}
+	Debugf("schoolId %s: %s %d bytes -> %d bytes at quality %d, %dx%d", schoolId, format, len(imgBytes), len(res.JPEG), res.Quality, res.Width, res.Height)
+	return &Photo{
+		Base64Data:     base64.StdEncoding.EncodeToString(res.JPEG),
+		OriginalSize:   len(imgBytes),
+		CompressedSize: len(res.JPEG),
+		Quality:        res.Quality,
+		Width:          res.Width,
+		Height:         res.Height,
+		DHash:          hash,
+		SHA256:         sum,
+		Status:         "ok",
+	}
 }
 
 func min(a, b int) int {
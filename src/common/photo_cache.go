@@ -0,0 +1,276 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	photoCacheMeta       = "meta.json"
+	photoCacheOriginal   = "original.bin"
+	photoCacheCompressed = "compressed.jpg"
+)
+
+// PhotoCache keeps the raw original and the compressed copy of every student
+// photo on disk, so a photo is only recompressed when iSAMS serves different
+// bytes. Each student gets a directory holding the two files and a meta.json
+// PhotoCacheEntry.
+type PhotoCache struct {
+	Dir string
+	// MaxAge is how long an entry may go unconfirmed by a sync before Evict
+	// removes it. Zero or less keeps entries forever.
+	MaxAge time.Duration
+}
+
+// PhotoCacheEntry describes one cached photo.
+type PhotoCacheEntry struct {
+	SchoolId       string `json:"school_id"`
+	SHA256         string `json:"sha256"`
+	ContentType    string `json:"content_type"`
+	OriginalSize   int    `json:"original_size"`
+	CompressedSize int    `json:"compressed_size"`
+	Quality        int    `json:"quality,omitempty"`
+	Width          int    `json:"width,omitempty"`
+	Height         int    `json:"height,omitempty"`
+	DHash          uint64 `json:"dhash,string,omitempty"`
+	Status         string `json:"status"`
+	// Pipeline records the decoder and compressor settings the copy was
+	// made with; a copy made with other settings is not reused.
+	Pipeline string `json:"pipeline"`
+	// ETag and LastModified are the validators iSAMS sent with the photo, if
+	// any; they let the next fetch skip the download.
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	// FetchedAt is when the original was last downloaded and processed;
+	// CheckedAt is when iSAMS last confirmed it was still current.
+	FetchedAt time.Time `json:"fetched_at"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// PhotoCacheStats summarises the cache for photos cache stats.
+type PhotoCacheStats struct {
+	Dir             string         `json:"dir"`
+	Entries         int            `json:"entries"`
+	OriginalBytes   int64          `json:"original_bytes"`
+	CompressedBytes int64          `json:"compressed_bytes"`
+	ByStatus        map[string]int `json:"by_status"`
+	OldestCheck     time.Time      `json:"oldest_check,omitempty"`
+	NewestCheck     time.Time      `json:"newest_check,omitempty"`
+}
+
+// NewPhotoCache returns a cache rooted at dir.
+func NewPhotoCache(dir string, maxAge time.Duration) *PhotoCache {
+	return &PhotoCache{Dir: dir, MaxAge: maxAge}
+}
+
+// PhotoSHA256 returns the hex SHA-256 of raw photo bytes.
+func PhotoSHA256(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *PhotoCache) entryDir(schoolId string) string {
+	return filepath.Join(c.Dir, url.PathEscape(schoolId))
+}
+
+// Get returns the entry for schoolId, or nil when there is none.
+func (c *PhotoCache) Get(schoolId string) (*PhotoCacheEntry, error) {
+	b, err := os.ReadFile(filepath.Join(c.entryDir(schoolId), photoCacheMeta))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entry PhotoCacheEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return nil, fmt.Errorf("photo cache %s: %w", schoolId, err)
+	}
+	return &entry, nil
+}
+
+// Photo rebuilds the Photo the entry was stored from.
+func (c *PhotoCache) Photo(entry *PhotoCacheEntry) (*Photo, error) {
+	if entry.Status != "ok" {
+		return &Photo{Status: entry.Status, DHash: entry.DHash, SHA256: entry.SHA256}, nil
+	}
+	compressed, err := os.ReadFile(filepath.Join(c.entryDir(entry.SchoolId), photoCacheCompressed))
+	if err != nil {
+		return nil, err
+	}
+	return &Photo{
+		Base64Data:     base64.StdEncoding.EncodeToString(compressed),
+		OriginalSize:   entry.OriginalSize,
+		CompressedSize: len(compressed),
+		Quality:        entry.Quality,
+		Width:          entry.Width,
+		Height:         entry.Height,
+		DHash:          entry.DHash,
+		SHA256:         entry.SHA256,
+		Status:         entry.Status,
+	}, nil
+}
+
+// Original returns the raw photo bytes stored for entry.
+func (c *PhotoCache) Original(entry *PhotoCacheEntry) ([]byte, error) {
+	return os.ReadFile(filepath.Join(c.entryDir(entry.SchoolId), photoCacheOriginal))
+}
+
+// Put stores the original bytes and the photo processed from them. The entry
+// is filled in from original and photo; its SchoolId, ContentType,
+// Pipeline and validators must already be set.
+func (c *PhotoCache) Put(entry *PhotoCacheEntry, original []byte, photo *Photo) error {
+	dir := c.entryDir(entry.SchoolId)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	now := time.Now()
+	entry.SHA256 = PhotoSHA256(original)
+	entry.OriginalSize = len(original)
+	entry.CompressedSize = photo.CompressedSize
+	entry.Quality = photo.Quality
+	entry.Width = photo.Width
+	entry.Height = photo.Height
+	entry.DHash = photo.DHash
+	entry.Status = photo.Status
+	entry.FetchedAt = now
+	entry.CheckedAt = now
+
+	if err := writeFileAtomic(filepath.Join(dir, photoCacheOriginal), original); err != nil {
+		return err
+	}
+	if photo.Status == "ok" {
+		compressed, err := base64.StdEncoding.DecodeString(photo.Base64Data)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(filepath.Join(dir, photoCacheCompressed), compressed); err != nil {
+			return err
+		}
+	} else {
+		os.Remove(filepath.Join(dir, photoCacheCompressed))
+	}
+	return c.writeMeta(entry)
+}
+
+// Touch records that iSAMS still serves the cached photo.
+func (c *PhotoCache) Touch(entry *PhotoCacheEntry) error {
+	entry.CheckedAt = time.Now()
+	return c.writeMeta(entry)
+}
+
+func (c *PhotoCache) writeMeta(entry *PhotoCacheEntry) error {
+	b, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(c.entryDir(entry.SchoolId), photoCacheMeta), b)
+}
+
+// Entries returns every entry in the cache, ordered by school ID. Entries
+// whose metadata cannot be read are skipped with a warning.
+func (c *PhotoCache) Entries() ([]*PhotoCacheEntry, error) {
+	dirs, err := os.ReadDir(c.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []*PhotoCacheEntry
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		schoolId, err := url.PathUnescape(d.Name())
+		if err != nil {
+			continue
+		}
+		entry, err := c.Get(schoolId)
+		if err != nil {
+			Warnf("skipping photo cache entry %s: %v", d.Name(), err)
+			continue
+		}
+		if entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].SchoolId < entries[j].SchoolId })
+	return entries, nil
+}
+
+// Stats summarises the cache contents.
+func (c *PhotoCache) Stats() (*PhotoCacheStats, error) {
+	entries, err := c.Entries()
+	if err != nil {
+		return nil, err
+	}
+	stats := &PhotoCacheStats{Dir: c.Dir, Entries: len(entries), ByStatus: map[string]int{}}
+	for _, e := range entries {
+		stats.OriginalBytes += int64(e.OriginalSize)
+		stats.CompressedBytes += int64(e.CompressedSize)
+		stats.ByStatus[e.Status]++
+		if stats.OldestCheck.IsZero() || e.CheckedAt.Before(stats.OldestCheck) {
+			stats.OldestCheck = e.CheckedAt
+		}
+		if e.CheckedAt.After(stats.NewestCheck) {
+			stats.NewestCheck = e.CheckedAt
+		}
+	}
+	return stats, nil
+}
+
+// Remove deletes the entry for schoolId.
+func (c *PhotoCache) Remove(schoolId string) error {
+	return os.RemoveAll(c.entryDir(schoolId))
+}
+
+// Evict removes entries not confirmed within olderThan, i.e. photos of
+// students that no sync has seen for that long. Zero or less removes every
+// entry. It returns the number of entries removed.
+func (c *PhotoCache) Evict(olderThan time.Duration) (int, error) {
+	entries, err := c.Entries()
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-olderThan)
+	removed := 0
+	for _, e := range entries {
+		if olderThan > 0 && e.CheckedAt.After(cutoff) {
+			continue
+		}
+		if err := c.Remove(e.SchoolId); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// writeFileAtomic writes b to a temporary file next to path and renames it
+// into place, so readers never see a partial file.
+func writeFileAtomic(path string, b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -1,33 +1,44 @@
 package common
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 )
 
+// ErrNoStudentPhoto is returned by DownloadStudentPhotoBytes when the student
+// has no current photo.
+var ErrNoStudentPhoto = errors.New("student has no photo")
+
 // DownloadStudentPhotoBytes downloads the current student photo as raw bytes without
-// any compression or resizing. Returns the bytes and the content type.
-func DownloadStudentPhotoBytes(schoolId, bearer string) ([]byte, string, error) {
-	url := fmt.Sprintf("https://alice-smith.isamshosting.cloud/Main/api/students/%s/photos/current", schoolId)
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", bearer)
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// any compression or resizing. Returns the bytes and the content type. The
+// bytes are not checked against the Content-Type; callers that decode them
+// rely on SniffPhotoFormat.
+func (c *ISAMSClient) DownloadStudentPhotoBytes(schoolId string) ([]byte, string, error) {
+	resp, err := c.do("GET", fmt.Sprintf("/students/%s/photos/current", schoolId), nil, "")
 	if err != nil {
 		return nil, "", err
 	}
 	defer resp.Body.Close()
 
 	contentType := resp.Header.Get("Content-Type")
-	if !strings.HasPrefix(contentType, "image/") {
-		return nil, contentType, fmt.Errorf("unexpected content type: %s", contentType)
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, contentType, ErrNoStudentPhoto
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, contentType, fmt.Errorf("download failed: status=%d", resp.StatusCode)
 	}
 
 	imgBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, contentType, err
 	}
+	// iSAMS labels some photos application/octet-stream; name the sniffed
+	// format instead so backups are restored with a usable type.
+	if format := SniffPhotoFormat(imgBytes); format != "" && !strings.HasPrefix(contentType, "image/") {
+		contentType = "image/" + format
+	}
 	return imgBytes, contentType, nil
 }
@@ -0,0 +1,45 @@
+package common
+
+import (
+	"log"
+	"sync"
+)
+
+// DefaultPhotoConcurrency is the number of photos fetched at once.
+const DefaultPhotoConcurrency = 8
+
+// FetchStudentPhotos fetches and compresses the current photo of every
+// school ID using up to concurrency workers, and returns them keyed by school
+// ID. The client's rate limit applies across all workers. A failed fetch is
+// logged and stored as a Photo whose Status explains the failure, so every
+// ID has an entry. With a photo cache, entries no fetch has confirmed within
+// the cache's MaxAge are evicted afterwards.
+func (c *ISAMSClient) FetchStudentPhotos(schoolIds []string, concurrency int) map[string]*Photo {
+	if concurrency <= 0 {
+		concurrency = DefaultPhotoConcurrency
+	}
+	var mu sync.Mutex
+	photos := make(map[string]*Photo, len(schoolIds))
+	RunConcurrent(len(schoolIds), concurrency, "photos", func(i int) {
+		schoolId := schoolIds[i]
+		photo, err := c.FetchStudentPhoto(schoolId)
+		if err != nil {
+			Warnf("could not fetch photo for schoolId %s: %v", schoolId, err)
+		}
+		if photo == nil {
+			photo = &Photo{Status: "error"}
+		}
+		mu.Lock()
+		photos[schoolId] = photo
+		mu.Unlock()
+	})
+
+	if c.PhotoCache != nil && c.PhotoCache.MaxAge > 0 {
+		if n, err := c.PhotoCache.Evict(c.PhotoCache.MaxAge); err != nil {
+			Warnf("photo cache eviction failed: %v", err)
+		} else if n > 0 {
+			log.Printf("Evicted %d stale photos from the cache.", n)
+		}
+	}
+	return photos
+}
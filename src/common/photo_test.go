@@ -0,0 +1,97 @@
+package common
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFetchStudentPhotoErrorStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		want    string
+		wantErr bool
+	}{
+		{name: "not found", status: http.StatusNotFound, want: "no photo"},
+		{name: "forbidden", status: http.StatusForbidden, want: "download error", wantErr: true},
+		{name: "server error", status: http.StatusInternalServerError, want: "download error", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/token" {
+					w.Write([]byte(`{"bearer_token":"t"}`))
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("ETag", `"err"`)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"error":"nope"}`))
+			}))
+			defer srv.Close()
+
+			c := NewISAMSClient(srv.URL, srv.URL+"/token")
+			c.PhotoCache = NewPhotoCache(t.TempDir(), time.Hour)
+			photo, err := c.FetchStudentPhoto("S1")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if photo == nil || photo.Status != tt.want {
+				t.Fatalf("photo = %+v, want status %q", photo, tt.want)
+			}
+			if entry, _ := c.PhotoCache.Get("S1"); entry != nil {
+				t.Errorf("error response was cached: %+v", entry)
+			}
+		})
+	}
+}
+
+func TestMatchStudentPhoto(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 120, 160))
+	for y := 0; y < 160; y++ {
+		for x := 0; x < 120; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 2), uint8(y), uint8(x + y), 255})
+		}
+	}
+	encode := func(img image.Image, q int) []byte {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: q}); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	c := NewISAMSClient("", "")
+	sent := encode(img, 90)
+	fp, err := c.FingerprintPhoto(sent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MatchStudentPhoto("S1", sent, fp); err != nil {
+		t.Errorf("identical bytes: %v", err)
+	}
+	if err := c.MatchStudentPhoto("S1", encode(img, 60), fp); err != nil {
+		t.Errorf("re-encoded copy: %v", err)
+	}
+	if err := c.MatchStudentPhoto("S1", encode(img.SubImage(image.Rect(0, 0, 100, 160)), 90), fp); err == nil {
+		t.Error("cropped copy matched")
+	}
+	other := image.NewRGBA(img.Bounds())
+	for i := range other.Pix {
+		other.Pix[i] = 255 - img.Pix[i]
+	}
+	for i := 3; i < len(other.Pix); i += 4 {
+		other.Pix[i] = 255
+	}
+	if err := c.MatchStudentPhoto("S1", encode(other, 90), fp); err == nil {
+		t.Error("different photo matched")
+	}
+	if err := c.MatchStudentPhoto("S1", encode(img, 60), PhotoFingerprint{SHA256: fp.SHA256}); err == nil {
+		t.Error("fingerprint without dimensions matched a re-encoded copy")
+	}
+}
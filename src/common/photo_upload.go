@@ -4,18 +4,61 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"net/http"
+	"time"
 )
 
+const (
+	// DefaultPhotoUploadAttempts is how many times an upload is tried before
+	// a photo that does not read back as sent is reported unverified.
+	DefaultPhotoUploadAttempts = 3
+	// photoVerifyMaxDistance is the largest hash distance at which a
+	// re-encoded copy still counts as the photo that was sent.
+	photoVerifyMaxDistance = 6
+)
+
+// photoVerifyBackoff is multiplied by the attempt number between attempts.
+var photoVerifyBackoff = 2 * time.Second
+
+// UnverifiedPhotoError is returned when iSAMS accepted an upload but the
+// current photo it serves afterwards is not the one that was sent.
+type UnverifiedPhotoError struct {
+	SchoolId string
+	Reason   string
+}
+
+func (e *UnverifiedPhotoError) Error() string {
+	return fmt.Sprintf("photo for %s not verified: %s", e.SchoolId, e.Reason)
+}
+
 // UploadStudentPhoto uploads a JPEG image to the student's photo endpoint.
-// The bearer must include the "Bearer " prefix.
-func UploadStudentPhoto(schoolId string, bearer string, jpegBytes []byte) error {
-	url := fmt.Sprintf("https://alice-smith.isamshosting.cloud/Main/api/students/%s/photos", schoolId)
-	req, _ := http.NewRequest("POST", url, bytes.NewReader(jpegBytes))
-	req.Header.Set("Authorization", bearer)
-	req.Header.Set("Content-Type", "image/jpeg")
-	client := &http.Client{}
-	resp, err := client.Do(req)
+func (c *ISAMSClient) UploadStudentPhoto(schoolId string, jpegBytes []byte) error {
+	return c.UploadStudentPhotoBytes(schoolId, jpegBytes, "image/jpeg")
+}
+
+// UploadStudentPhotoBytes uploads an image of any content type as the
+// student's current photo, then reads the current photo back with
+// VerifyStudentPhoto. A photo that does not verify is uploaded again, up to
+// UploadAttempts times, before an *UnverifiedPhotoError is returned.
+func (c *ISAMSClient) UploadStudentPhotoBytes(schoolId string, data []byte, contentType string) error {
+	attempts := max(c.UploadAttempts, 1)
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err := c.postStudentPhoto(schoolId, data, contentType); err != nil {
+			return err
+		}
+		if err = c.VerifyStudentPhoto(schoolId, data); err == nil {
+			return nil
+		}
+		if attempt < attempts {
+			Warnf("%v; uploading again (attempt %d of %d)", err, attempt+1, attempts)
+			time.Sleep(time.Duration(attempt) * photoVerifyBackoff)
+		}
+	}
+	return err
+}
+
+func (c *ISAMSClient) postStudentPhoto(schoolId string, data []byte, contentType string) error {
+	resp, err := c.do("POST", fmt.Sprintf("/students/%s/photos", schoolId), data, contentType)
 	if err != nil {
 		return err
 	}
@@ -26,3 +69,64 @@ func UploadStudentPhoto(schoolId string, bearer string, jpegBytes []byte) error
 	}
 	return nil
 }
+
+// PhotoFingerprint identifies a sent photo well enough to recognise it after
+// iSAMS re-encodes it, without keeping its bytes.
+type PhotoFingerprint struct {
+	SHA256        string
+	Width, Height int
+	DHash         uint64
+}
+
+// FingerprintPhoto decodes b and returns its fingerprint.
+func (c *ISAMSClient) FingerprintPhoto(b []byte) (PhotoFingerprint, error) {
+	img, _, err := c.decoders().Decode(b)
+	if err != nil {
+		return PhotoFingerprint{SHA256: PhotoSHA256(b)}, err
+	}
+	bounds := img.Bounds()
+	return PhotoFingerprint{SHA256: PhotoSHA256(b), Width: bounds.Dx(), Height: bounds.Dy(), DHash: DHash(img)}, nil
+}
+
+// VerifyStudentPhoto downloads the student's current photo and checks that
+// it is the photo that was sent; see MatchStudentPhoto.
+func (c *ISAMSClient) VerifyStudentPhoto(schoolId string, sent []byte) error {
+	current, _, err := c.DownloadStudentPhotoBytes(schoolId)
+	if err != nil {
+		return &UnverifiedPhotoError{SchoolId: schoolId, Reason: fmt.Sprintf("could not read back: %v", err)}
+	}
+	if bytes.Equal(current, sent) {
+		return nil
+	}
+	want, err := c.FingerprintPhoto(sent)
+	if err != nil {
+		return &UnverifiedPhotoError{SchoolId: schoolId, Reason: fmt.Sprintf("uploaded photo unreadable: %v", err)}
+	}
+	return c.MatchStudentPhoto(schoolId, current, want)
+}
+
+// MatchStudentPhoto checks that current, the student's photo as iSAMS serves
+// it, is the photo fingerprinted as sent. Identical bytes match at once;
+// otherwise iSAMS may have re-encoded the upload, so current is decoded and
+// must have the same dimensions and a near-identical perceptual hash. A
+// fingerprint without dimensions only matches identical bytes.
+func (c *ISAMSClient) MatchStudentPhoto(schoolId string, current []byte, sent PhotoFingerprint) error {
+	if PhotoSHA256(current) == sent.SHA256 {
+		return nil
+	}
+	if sent.Width == 0 || sent.Height == 0 {
+		return &UnverifiedPhotoError{SchoolId: schoolId, Reason: "current photo is not the one sent"}
+	}
+	got, err := c.FingerprintPhoto(current)
+	if err != nil {
+		return &UnverifiedPhotoError{SchoolId: schoolId, Reason: fmt.Sprintf("current photo unreadable: %v", err)}
+	}
+	if got.Width != sent.Width || got.Height != sent.Height {
+		return &UnverifiedPhotoError{SchoolId: schoolId, Reason: fmt.Sprintf("current photo is %dx%d, sent %dx%d", got.Width, got.Height, sent.Width, sent.Height)}
+	}
+	if d := HashDistance(sent.DHash, got.DHash); d > photoVerifyMaxDistance {
+		return &UnverifiedPhotoError{SchoolId: schoolId, Reason: fmt.Sprintf("current photo differs from the one sent (hash distance %d)", d)}
+	}
+	Debugf("%s: current photo is a re-encoded copy of the upload (%d bytes)", schoolId, len(current))
+	return nil
+}
@@ -0,0 +1,114 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PlanVersion is bumped whenever the plan file layout changes.
+const PlanVersion = 2
+
+// Plan is a reviewed-before-applied set of User_Master changes. It records a
+// fingerprint of the view it was computed against so that apply can refuse
+// to run once the view has moved on.
+type Plan struct {
+	Version     int        `json:"version"`
+	Command     string     `json:"command"`
+	CreatedAt   time.Time  `json:"created_at"`
+	View        string     `json:"view"`
+	Fingerprint string     `json:"fingerprint"`
+	Records     int        `json:"records"`
+	Changes     *Changeset `json:"changes"`
+}
+
+// NewPlan captures changes together with the state of the view they were
+// computed from.
+func NewPlan(command string, current []map[string]interface{}, changes *Changeset) *Plan {
+	return &Plan{
+		Version:     PlanVersion,
+		Command:     command,
+		CreatedAt:   time.Now().UTC(),
+		View:        changes.View,
+		Fingerprint: FingerprintRecords(current),
+		Records:     len(current),
+		Changes:     changes,
+	}
+}
+
+// WritePlan saves the plan as indented JSON.
+func WritePlan(path string, p *Plan) error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal plan: %w", err)
+	}
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		return fmt.Errorf("write plan: %w", err)
+	}
+	return nil
+}
+
+// ReadPlan loads a plan written by WritePlan.
+func ReadPlan(path string) (*Plan, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read plan: %w", err)
+	}
+	var p Plan
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("decode plan: %w", err)
+	}
+	if p.Version != PlanVersion {
+		return nil, fmt.Errorf("plan %s has version %d, expected %d", path, p.Version, PlanVersion)
+	}
+	if p.Changes == nil || p.View == "" {
+		return nil, fmt.Errorf("plan %s has no changes", path)
+	}
+	return &p, nil
+}
+
+// FingerprintRecords hashes the content of a User_Master view. Kissflow
+// bookkeeping fields (everything starting with "_" apart from _id) are left
+// out so that merely reading the view does not change the fingerprint.
+func FingerprintRecords(records []map[string]interface{}) string {
+	normalised := make([]map[string]string, 0, len(records))
+	for _, rec := range records {
+		n := make(map[string]string, len(rec))
+		for k, v := range rec {
+			if strings.HasPrefix(k, "_") && k != "_id" {
+				continue
+			}
+			n[k] = FieldString(v)
+		}
+		normalised = append(normalised, n)
+	}
+	sort.Slice(normalised, func(i, j int) bool { return normalised[i]["_id"] < normalised[j]["_id"] })
+
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, n := range normalised {
+		_ = enc.Encode(n)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ApplyPlan re-reads the plan's view and applies the recorded changes only if
+// the view still matches what the plan was computed against.
+func (c *KissflowClient) ApplyPlan(p *Plan, guard *DeletionGuard) error {
+	current, err := c.FetchUserMasterRecords(p.View)
+	if err != nil {
+		return err
+	}
+	if fp := FingerprintRecords(current); fp != p.Fingerprint {
+		return fmt.Errorf("User_Master %s has changed since the plan was made at %s (%d records then, %d now); re-run %s -plan",
+			p.View, p.CreatedAt.Format(time.RFC3339), p.Records, len(current), p.Command)
+	}
+	log.Printf("Applying %s plan from %s", p.Command, p.CreatedAt.Format(time.RFC3339))
+	return c.ApplyChangeset(p.Changes, guard)
+}
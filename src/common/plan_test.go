@@ -0,0 +1,51 @@
+package common
+
+import "testing"
+
+func TestFingerprintRecords(t *testing.T) {
+	base := []map[string]interface{}{
+		{"_id": "1", "Name": "A", "YearGroup": 7.0},
+		{"_id": "2", "Name": "B"},
+	}
+	tests := []struct {
+		name    string
+		records []map[string]interface{}
+		same    bool
+	}{
+		{
+			name: "order does not matter",
+			records: []map[string]interface{}{
+				{"_id": "2", "Name": "B"},
+				{"_id": "1", "Name": "A", "YearGroup": 7.0},
+			},
+			same: true,
+		},
+		{
+			name: "bookkeeping fields are ignored",
+			records: []map[string]interface{}{
+				{"_id": "1", "Name": "A", "YearGroup": "7", "_modified_at": "now"},
+				{"_id": "2", "Name": "B", "_created_by": "x"},
+			},
+			same: true,
+		},
+		{
+			name: "a changed field changes it",
+			records: []map[string]interface{}{
+				{"_id": "1", "Name": "A", "YearGroup": 8.0},
+				{"_id": "2", "Name": "B"},
+			},
+		},
+		{
+			name:    "a missing record changes it",
+			records: base[:1],
+		},
+	}
+	want := FingerprintRecords(base)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FingerprintRecords(tt.records) == want; got != tt.same {
+				t.Errorf("same fingerprint = %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package common
+
+import "sync"
+
+// RunConcurrent calls fn for every index in [0, n) on up to concurrency
+// goroutines and logs progress under label. fn must be safe to call
+// concurrently; it should record its own results.
+func RunConcurrent(n, concurrency int, label string, fn func(i int)) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	progress := NewProgress(label, n)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+				progress.Add(1)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
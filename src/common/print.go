@@ -0,0 +1,46 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+
+	"golang.org/x/image/draw"
+)
+
+// Print profile defaults: large enough for a 300 dpi photo on an ID card.
+const (
+	DefaultPrintMaxWidth  = 1200
+	DefaultPrintMaxHeight = 1600
+	DefaultPrintQuality   = 92
+)
+
+// EncodePrintPhoto shrinks img to fit maxWidth x maxHeight, keeping its
+// aspect ratio and never enlarging it, and encodes it as JPEG at quality. A
+// zero limit leaves that dimension unbounded.
+func EncodePrintPhoto(img image.Image, maxWidth, maxHeight, quality int) (*CompressResult, error) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w == 0 || h == 0 {
+		return nil, fmt.Errorf("empty image")
+	}
+	scale := 1.0
+	if maxWidth > 0 && w > maxWidth {
+		scale = float64(maxWidth) / float64(w)
+	}
+	if maxHeight > 0 && float64(h)*scale > float64(maxHeight) {
+		scale = float64(maxHeight) / float64(h)
+	}
+	if scale < 1 {
+		dst := image.NewRGBA(image.Rect(0, 0, max(int(float64(w)*scale), 1), max(int(float64(h)*scale), 1)))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+		img = dst
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	ob := img.Bounds()
+	return &CompressResult{JPEG: buf.Bytes(), Quality: quality, Width: ob.Dx(), Height: ob.Dy()}, nil
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Progress logs how far a long-running job has got, with its throughput and
+// an ETA. It is safe for concurrent use.
+type Progress struct {
+	Label string
+	Total int
+	// Every is the minimum time between two log lines.
+	Every time.Duration
+
+	mu      sync.Mutex
+	done    int
+	start   time.Time
+	lastLog time.Time
+}
+
+// NewProgress starts tracking a job of total items.
+func NewProgress(label string, total int) *Progress {
+	now := time.Now()
+	return &Progress{Label: label, Total: total, Every: 10 * time.Second, start: now, lastLog: now}
+}
+
+// Add records n finished items and logs when Every has passed or the job is
+// complete.
+func (p *Progress) Add(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done += n
+	now := time.Now()
+	if p.done < p.Total && now.Sub(p.lastLog) < p.Every {
+		return
+	}
+	p.lastLog = now
+	log.Print(p.lineLocked(now))
+}
+
+func (p *Progress) lineLocked(now time.Time) string {
+	elapsed := now.Sub(p.start)
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(p.done) / elapsed.Seconds()
+	}
+	pct := 100.0
+	if p.Total > 0 {
+		pct = float64(p.done) * 100 / float64(p.Total)
+	}
+	eta := "unknown"
+	switch {
+	case p.done >= p.Total:
+		eta = "done in " + elapsed.Round(time.Second).String()
+	case rate > 0:
+		eta = "ETA " + time.Duration(float64(p.Total-p.done)/rate*float64(time.Second)).Round(time.Second).String()
+	}
+	return fmt.Sprintf("%s: %d/%d (%.0f%%), %.1f/s, %s", p.Label, p.done, p.Total, pct, rate, eta)
+}
@@ -0,0 +1,285 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FieldChange is a single field whose User_Master value differs from the
+// desired payload.
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// RecordChange describes what will happen to one User_Master record. Before
+// is the record as currently stored (nil for creates) and After is the payload
+// that will be sent (nil for deletes; only the changed fields for
+// deactivations).
+type RecordChange struct {
+	ID     string                 `json:"_id"`
+	Before map[string]interface{} `json:"before,omitempty"`
+	After  map[string]interface{} `json:"after,omitempty"`
+	Fields []FieldChange          `json:"fields,omitempty"`
+}
+
+// Changeset is the difference between a User_Master view and the records a
+// sync command wants it to contain.
+type Changeset struct {
+	View      string         `json:"view"`
+	Creates   []RecordChange `json:"creates"`
+	Updates   []RecordChange `json:"updates"`
+	Deletes   []RecordChange `json:"deletes"`
+	Unchanged int            `json:"unchanged"`
+	// Owned is how many current records in the view belong to this sync.
+	Owned int `json:"owned"`
+	// Desired is how many records the source produced.
+	Desired int `json:"desired"`
+	// Deactivates are owned records that left the source and are set
+	// inactive instead of deleted; see Deactivation.
+	Deactivates []RecordChange `json:"deactivates,omitempty"`
+}
+
+// ReconcileOptions tunes how current and desired records are compared.
+type ReconcileOptions struct {
+	// PresenceOnly fields are only compared on whether they hold a value.
+	// image_1 is stored by Kissflow as an attachment rather than the base64
+	// we send, so its content can never be compared directly.
+	PresenceOnly []string
+	// Owns reports whether a current record belongs to this sync. Records
+	// that are not owned are never deleted. A nil Owns owns the whole view.
+	Owns func(rec map[string]interface{}) bool
+	// Deactivate, when set, deactivates owned records that are no longer
+	// desired instead of deleting them. Records already deactivated are
+	// left as they are.
+	Deactivate *Deactivation
+}
+
+// Reconcile compares the current records of a User_Master view with the
+// desired payloads, keyed by _id, and returns the creates, updates and
+// deletes (or deactivations) needed to make the view match. A desired
+// record that was deactivated has its deactivation date and reason cleared.
+func Reconcile(view string, current, desired []map[string]interface{}, opts ReconcileOptions) *Changeset {
+	presenceOnly := make(map[string]bool)
+	for _, f := range opts.PresenceOnly {
+		presenceOnly[f] = true
+	}
+
+	currentById := make(map[string]map[string]interface{}, len(current))
+	for _, rec := range current {
+		currentById[FieldString(rec["_id"])] = rec
+	}
+
+	cs := &Changeset{View: view, Desired: len(desired)}
+	seen := make(map[string]bool, len(desired))
+	for _, want := range desired {
+		id := FieldString(want["_id"])
+		if id == "" {
+			log.Printf("reconcile %s: skipping payload without _id: %v", view, want["Name"])
+			continue
+		}
+		if seen[id] {
+			log.Printf("reconcile %s: duplicate payload for _id %s, keeping the last one", view, id)
+			removeChange(cs, id)
+		}
+		seen[id] = true
+
+		have, exists := currentById[id]
+		if !exists {
+			cs.Creates = append(cs.Creates, RecordChange{ID: id, After: want})
+			continue
+		}
+		want = reactivated(have, want)
+		fields := diffFields(have, want, presenceOnly)
+		if len(fields) == 0 {
+			cs.Unchanged++
+			continue
+		}
+		cs.Updates = append(cs.Updates, RecordChange{ID: id, Before: have, After: want, Fields: fields})
+	}
+
+	for id, have := range currentById {
+		if opts.Owns != nil && !opts.Owns(have) {
+			continue
+		}
+		cs.Owned++
+		if seen[id] || id == "" {
+			continue
+		}
+		if opts.Deactivate == nil {
+			cs.Deletes = append(cs.Deletes, RecordChange{ID: id, Before: have})
+			continue
+		}
+		if IsDeactivated(have) {
+			cs.Unchanged++
+			continue
+		}
+		after := opts.Deactivate.payload(id, have)
+		cs.Deactivates = append(cs.Deactivates, RecordChange{ID: id, Before: have, After: after, Fields: diffFields(have, after, presenceOnly)})
+	}
+
+	sortChanges(cs.Creates)
+	sortChanges(cs.Updates)
+	sortChanges(cs.Deletes)
+	sortChanges(cs.Deactivates)
+	return cs
+}
+
+// removeChange drops an earlier create/update for id so a duplicate payload
+// replaces it instead of being applied twice.
+func removeChange(cs *Changeset, id string) {
+	filter := func(changes []RecordChange) []RecordChange {
+		out := changes[:0]
+		for _, c := range changes {
+			if c.ID != id {
+				out = append(out, c)
+			}
+		}
+		return out
+	}
+	before := len(cs.Creates) + len(cs.Updates)
+	cs.Creates = filter(cs.Creates)
+	cs.Updates = filter(cs.Updates)
+	if len(cs.Creates)+len(cs.Updates) == before {
+		cs.Unchanged--
+	}
+}
+
+func diffFields(have, want map[string]interface{}, presenceOnly map[string]bool) []FieldChange {
+	var fields []FieldChange
+	for field, value := range want {
+		if field == "_id" {
+			continue
+		}
+		before := FieldString(have[field])
+		after := FieldString(value)
+		if presenceOnly[field] {
+			if (before == "") != (after == "") {
+				fields = append(fields, FieldChange{Field: field, Before: presence(before), After: presence(after)})
+			}
+			continue
+		}
+		if before != after {
+			fields = append(fields, FieldChange{Field: field, Before: before, After: after})
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+	return fields
+}
+
+func presence(v string) string {
+	if v == "" {
+		return "(empty)"
+	}
+	return "(set)"
+}
+
+func sortChanges(changes []RecordChange) {
+	sort.Slice(changes, func(i, j int) bool { return changes[i].ID < changes[j].ID })
+}
+
+// FieldString normalises a User_Master field value for comparison. Kissflow
+// returns numbers and empty fields in several shapes, so everything is
+// compared as trimmed text.
+func FieldString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(t)
+	case bool:
+		return strconv.FormatBool(t)
+	default:
+		b, err := json.Marshal(t)
+		if err != nil {
+			return fmt.Sprintf("%v", t)
+		}
+		s := string(b)
+		if s == "[]" || s == "{}" || s == "null" {
+			return ""
+		}
+		return s
+	}
+}
+
+// Empty reports whether the changeset has nothing to apply.
+func (cs *Changeset) Empty() bool {
+	return len(cs.Creates) == 0 && len(cs.Updates) == 0 && len(cs.Deletes) == 0 && len(cs.Deactivates) == 0
+}
+
+// Removals is how many records leave the active view: deletions and
+// deactivations alike.
+func (cs *Changeset) Removals() int {
+	return len(cs.Deletes) + len(cs.Deactivates)
+}
+
+// Summary returns a one-line description of the changeset.
+func (cs *Changeset) Summary() string {
+	return fmt.Sprintf("User_Master %s: %d to create, %d to update, %d to deactivate, %d to delete, %d unchanged",
+		cs.View, len(cs.Creates), len(cs.Updates), len(cs.Deactivates), len(cs.Deletes), cs.Unchanged)
+}
+
+// FetchUserMasterRecords lists every record in a User_Master view with all of
+// its fields, for use with Reconcile.
+func (c *KissflowClient) FetchUserMasterRecords(view string) ([]map[string]interface{}, error) {
+	records, err := ListView[map[string]interface{}](c, c.UserMasterDataset, view)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch User_Master %s records: %w", view, err)
+	}
+	return records, nil
+}
+
+// ApplyChangeset sends creates, updates and deactivations through the batch
+// endpoint and then removes deleted records. Upserts go first so nobody loses access while
+// the view is being brought up to date. The guard is checked before anything
+// is sent; a nil guard disables it.
+func (c *KissflowClient) ApplyChangeset(cs *Changeset, guard *DeletionGuard) error {
+	log.Println(cs.Summary())
+	if guard != nil {
+		if err := guard.Check(cs); err != nil {
+			return err
+		}
+	}
+
+	var upserts []map[string]interface{}
+	for _, rc := range cs.Creates {
+		upserts = append(upserts, rc.After)
+	}
+	for _, rc := range cs.Updates {
+		upserts = append(upserts, rc.After)
+	}
+	for _, rc := range cs.Deactivates {
+		upserts = append(upserts, rc.After)
+	}
+	if len(upserts) > 0 {
+		if err := c.SendToUserMasterBatch(upserts); err != nil {
+			return err
+		}
+	}
+
+	if len(cs.Deletes) > 0 {
+		var records []UserMasterRecord
+		for _, rc := range cs.Deletes {
+			records = append(records, UserMasterRecord{ID: rc.ID, Name: FieldString(rc.Before["Name"])})
+		}
+		if err := c.DeleteAllUserMaster(records); err != nil {
+			return err
+		}
+	}
+
+	if guard != nil {
+		if err := guard.RecordSuccess(cs); err != nil {
+			Warnf("could not record sync state: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffFields(t *testing.T) {
+	presenceOnly := map[string]bool{"image_1": true}
+	tests := []struct {
+		name string
+		have map[string]interface{}
+		want map[string]interface{}
+		diff []FieldChange
+	}{
+		{
+			name: "equal",
+			have: map[string]interface{}{"_id": "1", "Name": "A"},
+			want: map[string]interface{}{"_id": "1", "Name": "A"},
+		},
+		{
+			name: "changed value",
+			have: map[string]interface{}{"Name": "A", "CardNo": "1"},
+			want: map[string]interface{}{"Name": "A", "CardNo": "2"},
+			diff: []FieldChange{{Field: "CardNo", Before: "1", After: "2"}},
+		},
+		{
+			name: "numbers and strings compare as text",
+			have: map[string]interface{}{"YearGroup": 7.0, "Status": " 1 "},
+			want: map[string]interface{}{"YearGroup": "7", "Status": "1"},
+		},
+		{
+			name: "missing field equals empty",
+			have: map[string]interface{}{},
+			want: map[string]interface{}{"IdentityNo": ""},
+		},
+		{
+			name: "fields only in have are ignored",
+			have: map[string]interface{}{"Name": "A", "Extra": "x"},
+			want: map[string]interface{}{"Name": "A"},
+		},
+		{
+			name: "_id is never a change",
+			have: map[string]interface{}{"_id": "1"},
+			want: map[string]interface{}{"_id": "2"},
+		},
+		{
+			name: "presence-only content is not compared",
+			have: map[string]interface{}{"image_1": []interface{}{map[string]interface{}{"key": "a.jpg"}}},
+			want: map[string]interface{}{"image_1": "base64"},
+		},
+		{
+			name: "presence-only set vs empty",
+			have: map[string]interface{}{},
+			want: map[string]interface{}{"image_1": "base64"},
+			diff: []FieldChange{{Field: "image_1", Before: "(empty)", After: "(set)"}},
+		},
+		{
+			name: "photo hash changes even though image_1 does not compare",
+			have: map[string]interface{}{"image_1": "stored", "PhotoSHA256": "aaa"},
+			want: map[string]interface{}{"image_1": "new", "PhotoSHA256": "bbb"},
+			diff: []FieldChange{{Field: "PhotoSHA256", Before: "aaa", After: "bbb"}},
+		},
+		{
+			name: "sorted by field",
+			have: map[string]interface{}{"B": "1", "A": "1"},
+			want: map[string]interface{}{"B": "2", "A": "2"},
+			diff: []FieldChange{{Field: "A", Before: "1", After: "2"}, {Field: "B", Before: "1", After: "2"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffFields(tt.have, tt.want, presenceOnly)
+			if !reflect.DeepEqual(got, tt.diff) {
+				t.Errorf("diffFields = %+v, want %+v", got, tt.diff)
+			}
+		})
+	}
+}
+
+func ids(changes []RecordChange) []string {
+	var out []string
+	for _, c := range changes {
+		out = append(out, c.ID)
+	}
+	return out
+}
+
+func TestReconcile(t *testing.T) {
+	rec := func(id string, kv ...string) map[string]interface{} {
+		m := map[string]interface{}{"_id": id, "Name": id}
+		for i := 0; i+1 < len(kv); i += 2 {
+			m[kv[i]] = kv[i+1]
+		}
+		return m
+	}
+	at := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		current     []map[string]interface{}
+		desired     []map[string]interface{}
+		opts        ReconcileOptions
+		creates     []string
+		updates     []string
+		deletes     []string
+		deactivates []string
+		unchanged   int
+		owned       int
+	}{
+		{
+			name:      "create update delete unchanged",
+			current:   []map[string]interface{}{rec("a"), rec("b", "CardNo", "1"), rec("c")},
+			desired:   []map[string]interface{}{rec("a"), rec("b", "CardNo", "2"), rec("d")},
+			creates:   []string{"d"},
+			updates:   []string{"b"},
+			deletes:   []string{"c"},
+			unchanged: 1,
+			owned:     3,
+		},
+		{
+			name:    "records not owned are never deleted",
+			current: []map[string]interface{}{rec("a", "AccessGroup", "FAMILY"), rec("b")},
+			opts: ReconcileOptions{Owns: func(r map[string]interface{}) bool {
+				return FieldString(r["AccessGroup"]) != "FAMILY"
+			}},
+			deletes: []string{"b"},
+			owned:   1,
+		},
+		{
+			name:    "duplicate payload keeps the last",
+			current: []map[string]interface{}{rec("a", "CardNo", "1")},
+			desired: []map[string]interface{}{rec("a", "CardNo", "2"), rec("a", "CardNo", "1")},
+			// The second payload matches the stored record.
+			unchanged: 1,
+			owned:     1,
+		},
+		{
+			name:    "payload without _id is skipped",
+			desired: []map[string]interface{}{{"Name": "x"}},
+		},
+		{
+			name:        "leavers are deactivated, not deleted",
+			current:     []map[string]interface{}{rec("a"), rec("b", "Status", "1")},
+			desired:     []map[string]interface{}{rec("a")},
+			opts:        ReconcileOptions{Deactivate: &Deactivation{Reason: "left", At: at}},
+			deactivates: []string{"b"},
+			unchanged:   1,
+			owned:       2,
+		},
+		{
+			name:      "already deactivated records are left alone",
+			current:   []map[string]interface{}{rec("b", "Status", "2", FieldDeactivatedOn, "2025-01-01")},
+			opts:      ReconcileOptions{Deactivate: &Deactivation{Reason: "left", At: at}},
+			unchanged: 1,
+			owned:     1,
+		},
+		{
+			name:    "returning leaver is reactivated",
+			current: []map[string]interface{}{rec("b", "Status", "2", FieldDeactivatedOn, "2025-01-01", FieldDeactivationReason, "left")},
+			desired: []map[string]interface{}{rec("b", "Status", "1")},
+			updates: []string{"b"},
+			owned:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := Reconcile("Students", tt.current, tt.desired, tt.opts)
+			if got := ids(cs.Creates); !reflect.DeepEqual(got, tt.creates) {
+				t.Errorf("creates = %v, want %v", got, tt.creates)
+			}
+			if got := ids(cs.Updates); !reflect.DeepEqual(got, tt.updates) {
+				t.Errorf("updates = %v, want %v", got, tt.updates)
+			}
+			if got := ids(cs.Deletes); !reflect.DeepEqual(got, tt.deletes) {
+				t.Errorf("deletes = %v, want %v", got, tt.deletes)
+			}
+			if got := ids(cs.Deactivates); !reflect.DeepEqual(got, tt.deactivates) {
+				t.Errorf("deactivates = %v, want %v", got, tt.deactivates)
+			}
+			if cs.Unchanged != tt.unchanged {
+				t.Errorf("unchanged = %d, want %d", cs.Unchanged, tt.unchanged)
+			}
+			if cs.Owned != tt.owned {
+				t.Errorf("owned = %d, want %d", cs.Owned, tt.owned)
+			}
+		})
+	}
+}
+
+func TestReconcileDeactivationPayload(t *testing.T) {
+	at := time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC)
+	current := []map[string]interface{}{{"_id": "b", "Name": "B1", "Status": "1", "CardNo": "5"}}
+	cs := Reconcile("Staff", current, nil, ReconcileOptions{Deactivate: &Deactivation{Reason: "left", At: at}})
+	if len(cs.Deactivates) != 1 {
+		t.Fatalf("deactivates = %d, want 1", len(cs.Deactivates))
+	}
+	want := map[string]interface{}{
+		"_id":                   "b",
+		"Name":                  "B1",
+		"Status":                StatusInactive,
+		FieldDeactivatedOn:      "2026-01-02",
+		FieldDeactivationReason: "left",
+	}
+	if got := cs.Deactivates[0].After; !reflect.DeepEqual(got, want) {
+		t.Errorf("payload = %v, want %v", got, want)
+	}
+	if cs.Removals() != 1 {
+		t.Errorf("removals = %d, want 1", cs.Removals())
+	}
+}
+
+func TestReconcileReactivationClearsFields(t *testing.T) {
+	current := []map[string]interface{}{{"_id": "b", "Status": "2", FieldDeactivatedOn: "2025-01-01", FieldDeactivationReason: "left"}}
+	desired := []map[string]interface{}{{"_id": "b", "Status": "1"}}
+	cs := Reconcile("Staff", current, desired, ReconcileOptions{})
+	want := []FieldChange{
+		{Field: FieldDeactivatedOn, Before: "2025-01-01", After: ""},
+		{Field: FieldDeactivationReason, Before: "left", After: ""},
+		{Field: "Status", Before: "2", After: "1"},
+	}
+	if len(cs.Updates) != 1 || !reflect.DeepEqual(cs.Updates[0].Fields, want) {
+		t.Fatalf("updates = %+v, want one with %+v", cs.Updates, want)
+	}
+	if _, ok := desired[0][FieldDeactivatedOn]; ok {
+		t.Error("Reconcile modified the desired payload")
+	}
+}
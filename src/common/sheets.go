@@ -0,0 +1,57 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
+)
+
+// NewSheetsService builds a Sheets client from a service account key file.
+func NewSheetsService(ctx context.Context, credentialsPath string) (*sheets.Service, error) {
+	b, err := os.ReadFile(credentialsPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read service account file: %w", err)
+	}
+	config, err := google.JWTConfigFromJSON(b, sheets.SpreadsheetsScope)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse service account file: %w", err)
+	}
+	srv, err := sheets.NewService(ctx, option.WithHTTPClient(config.Client(ctx)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve Sheets client: %w", err)
+	}
+	return srv, nil
+}
+
+// ReplaceSheetValues clears the sheet and writes values from A1 in batches of
+// batchSize rows. A batchSize of zero writes everything in one request.
+func ReplaceSheetValues(srv *sheets.Service, spreadsheetId, sheetName string, values [][]interface{}, batchSize int) error {
+	if _, err := srv.Spreadsheets.Values.Clear(spreadsheetId, sheetName, &sheets.ClearValuesRequest{}).Do(); err != nil {
+		return fmt.Errorf("unable to clear sheet: %w", err)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	if batchSize <= 0 {
+		batchSize = len(values)
+	}
+	rowStart := 1
+	for i := 0; i < len(values); i += batchSize {
+		end := i + batchSize
+		if end > len(values) {
+			end = len(values)
+		}
+		batch := values[i:end]
+		rangeStr := fmt.Sprintf("%s!A%d", sheetName, rowStart)
+		vr := &sheets.ValueRange{Values: batch}
+		if _, err := srv.Spreadsheets.Values.Update(spreadsheetId, rangeStr, vr).ValueInputOption("RAW").Do(); err != nil {
+			return fmt.Errorf("unable to write to sheet: %w", err)
+		}
+		rowStart += len(batch)
+	}
+	return nil
+}
@@ -3,17 +3,10 @@ package common
 import (
 	"encoding/json"
 	"fmt"
-	"net/http"
+	"io"
+	"strings"
 )
 
-const (
-	studentsAPI = "https://alice-smith.isamshosting.cloud/Main/api/students"
-)
-
-type bearerTokenResponse struct {
-	BearerToken string `json:"bearer_token"`
-}
-
 type Student struct {
 	SchoolId    string      `json:"schoolId"`
 	FullName    string      `json:"fullName"`
@@ -31,43 +24,49 @@ type studentsResponse struct {
 	Students []Student `json:"students"`
 }
 
-// GetBearerToken retrieves the bearer token string from the provided API key URL.
-func GetBearerToken(apiKeyUrl string) (string, error) {
-	resp, err := http.Get(apiKeyUrl)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	var tokenResp bearerTokenResponse
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return "", err
-	}
-	return tokenResp.BearerToken, nil
-}
-
 // FetchAllStudents pages through the Students API and returns all students.
-func FetchAllStudents(bearer string) ([]Student, error) {
+func (c *ISAMSClient) FetchAllStudents() ([]Student, error) {
 	students := []Student{}
 	page := 1
 	for {
-		url := fmt.Sprintf("%s?page=%d&pageSize=%d", studentsAPI, page, PAGE_SIZE)
-		req, _ := http.NewRequest("GET", url, nil)
-		req.Header.Set("Authorization", bearer)
-		client := &http.Client{}
-		resp, err := client.Do(req)
+		path := fmt.Sprintf("/students?page=%d&pageSize=%d", page, c.PageSize)
+		sr, err := c.fetchStudentsPage(path)
 		if err != nil {
 			return nil, err
 		}
-		defer resp.Body.Close()
-		var sr studentsResponse
-		if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
-			return nil, err
-		}
 		students = append(students, sr.Students...)
-		if len(sr.Students) < PAGE_SIZE {
+		if len(sr.Students) < c.PageSize {
 			break
 		}
 		page++
 	}
 	return students, nil
 }
+
+func (c *ISAMSClient) fetchStudentsPage(path string) (*studentsResponse, error) {
+	resp, err := c.do("GET", path, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("students API error: status=%d, body=%s", resp.StatusCode, string(body))
+	}
+	var sr studentsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+		return nil, err
+	}
+	return &sr, nil
+}
+
+// IsEPYearGroup reports whether a student's year group (7-13) belongs to
+// the Equine Park campus.
+func IsEPYearGroup(yearGroup interface{}) bool {
+	switch strings.TrimSpace(fmt.Sprintf("%v", yearGroup)) {
+	case "7", "8", "9", "10", "11", "12", "13":
+		return true
+	default:
+		return false
+	}
+}
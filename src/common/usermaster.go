@@ -1,65 +1,48 @@
 package common
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"net/http"
 )
 
-func DeleteAllUserMaster(accessKeyId, accessKeySecret string, records []map[string]string) error {
-	deleteURL := "https://alice-smith.kissflow.com/dataset/2/AcflcLIlo4aq/User_Master"
+// UserMasterRecord identifies a User_Master row.
+type UserMasterRecord struct {
+	ID   string `json:"_id"`
+	Name string `json:"Name"`
+}
+
+// DeleteAllUserMaster deletes every record, carrying on past failures. It
+// returns the failures joined, so a partial delete is reported as an error.
+func (c *KissflowClient) DeleteAllUserMaster(records []UserMasterRecord) error {
+	var errs []error
 	for _, rec := range records {
-		jsonPayload, _ := json.Marshal(rec)
-		req, _ := http.NewRequest("DELETE", deleteURL, bytes.NewReader(jsonPayload))
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-Access-Key-Id", accessKeyId)
-		req.Header.Set("X-Access-Key-Secret", accessKeySecret)
-		client := &http.Client{}
-		resp, err := client.Do(req)
+		resp, err := c.do("DELETE", c.UserMasterDataset, rec)
 		if err != nil {
-			log.Printf("Failed to delete User_Master record %s: %v", rec["_id"], err)
-			continue
-		}
-		defer resp.Body.Close()
-		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			body, _ := ioutil.ReadAll(resp.Body)
-			log.Printf("Failed to delete User_Master record %s: %s", rec["_id"], string(body))
+			log.Printf("Failed to delete User_Master record %s: %v", rec.ID, err)
+			errs = append(errs, fmt.Errorf("delete %s: %w", rec.ID, err))
 			continue
 		}
-		log.Printf("Deleted User_Master record: %s", rec["_id"])
+		resp.Body.Close()
+		log.Printf("Deleted User_Master record: %s", rec.ID)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d of %d User_Master deletes failed: %w", len(errs), len(records), errors.Join(errs...))
 	}
 	return nil
 }
 
-func SendToUserMasterBatch(payloads []map[string]interface{}, accessKeyId, accessKeySecret string) error {
-	for i := 0; i < len(payloads); i += BATCH_SIZE {
-		end := i + BATCH_SIZE
+func (c *KissflowClient) SendToUserMasterBatch(payloads []map[string]interface{}) error {
+	for i := 0; i < len(payloads); i += c.BatchSize {
+		end := i + c.BatchSize
 		if end > len(payloads) {
 			end = len(payloads)
 		}
-		batch := payloads[i:end]
-		jsonPayload, err := json.Marshal(batch)
-		if err != nil {
-			return fmt.Errorf("failed to marshal batch payload: %w", err)
-		}
-		req, _ := http.NewRequest("POST", USER_MASTER_BATCH_API, bytes.NewReader(jsonPayload))
-		req.Header.Set("X-Access-Key-Id", accessKeyId)
-		req.Header.Set("X-Access-Key-Secret", accessKeySecret)
-		req.Header.Set("Accept", "application/json")
-		req.Header.Set("Content-Type", "application/json")
-		client := &http.Client{}
-		resp, err := client.Do(req)
+		resp, err := c.do("POST", c.UserMasterDataset+"/batch", payloads[i:end])
 		if err != nil {
 			return fmt.Errorf("failed to send batch: %w", err)
 		}
-		defer resp.Body.Close()
-		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			body, _ := ioutil.ReadAll(resp.Body)
-			return fmt.Errorf("batch API error: %s", string(body))
-		}
+		resp.Body.Close()
 		log.Printf("Batch %d-%d sent successfully", i+1, end)
 	}
 	return nil
@@ -1,22 +1,20 @@
-package main
+package csvreport
 
 import (
 	"encoding/csv"
 	"fmt"
+	"log"
 	"os"
 	"strings"
 )
 
-// processCSV extracts rows where column G contains the word "FAMILY" from the raw export
-// and writes them into a new CSV file. This is a helper utility and should be
-// invoked manually. Renaming the entry-point from `main` avoids clashing with the
-// primary CLI binary built from this package.
-func processCSV() {
+// Families extracts rows where column G contains the word "FAMILY" from the
+// raw export and writes them into a new CSV file.
+func Families(inPath, outPath string) error {
 	// Open the input CSV file
-	inputFile, err := os.Open("P1 User July.csv")
+	inputFile, err := os.Open(inPath)
 	if err != nil {
-		fmt.Printf("Error opening input file: %v\n", err)
-		return
+		return fmt.Errorf("error opening input file: %w", err)
 	}
 	defer inputFile.Close()
 
@@ -25,10 +23,9 @@ func processCSV() {
 	reader.FieldsPerRecord = -1 // Allow variable number of fields
 
 	// Create output CSV file
-	outputFile, err := os.Create("column_g_family.csv")
+	outputFile, err := os.Create(outPath)
 	if err != nil {
-		fmt.Printf("Error creating output file: %v\n", err)
-		return
+		return fmt.Errorf("error creating output file: %w", err)
 	}
 	defer outputFile.Close()
 
@@ -38,8 +35,7 @@ func processCSV() {
 
 	// Write header
 	if err := writer.Write([]string{"Column G"}); err != nil {
-		fmt.Printf("Error writing header: %v\n", err)
-		return
+		return fmt.Errorf("error writing header: %w", err)
 	}
 
 	// Process each row
@@ -55,12 +51,13 @@ func processCSV() {
 			// Check if column G contains "FAMILY" (case insensitive)
 			if strings.Contains(strings.ToUpper(columnG), "FAMILY") {
 				if err := writer.Write([]string{columnG}); err != nil {
-					fmt.Printf("Error writing record: %v\n", err)
-					return
+					return fmt.Errorf("error writing record: %w", err)
 				}
 			}
 		}
 	}
 
-	fmt.Println("Processing complete. Results saved to column_g_family.csv")
+	log.Printf("Processing complete. Results saved to %s", outPath)
+	return nil
+
 }
@@ -1,14 +1,26 @@
-package main
+// Package csvreport holds small reports over the raw P1 access control CSV
+// exports.
+package csvreport
 
 import (
 	"encoding/csv"
-	"flag"
 	"fmt"
+	"io"
 	"os"
 	"sort"
 	"strings"
 )
 
+// GroupOptions configures Group.
+type GroupOptions struct {
+	InPath  string
+	OutPath string
+	// Column is a letter like G or a zero-based index like 6.
+	Column string
+	// Header skips the first row of the input.
+	Header bool
+}
+
 // columnRefToIndex converts a spreadsheet-like column reference to zero-based index.
 // Accepts either a number (e.g. "6" -> 6) or a letter (e.g. "G" -> 6).
 func columnRefToIndex(ref string) (int, error) {
@@ -41,30 +53,17 @@ func columnRefToIndex(ref string) (int, error) {
 	return value - 1, nil
 }
 
-func main() {
-	var (
-		inputPath  string
-		outputPath string
-		columnRef  string
-		hasHeader  bool
-	)
-
-	flag.StringVar(&inputPath, "in", "export.csv", "Path to input CSV file")
-	flag.StringVar(&outputPath, "out", "group_by_G.csv", "Path to output CSV summary file")
-	flag.StringVar(&columnRef, "col", "G", "Column to group by (letter like G or zero-based index like 6)")
-	flag.BoolVar(&hasHeader, "header", false, "Set true if the CSV has a header row to skip")
-	flag.Parse()
-
-	colIndex, err := columnRefToIndex(columnRef)
+// Group counts the rows of a CSV by the value of one column and writes the
+// counts, largest first, to OutPath.
+func Group(stdout io.Writer, opts GroupOptions) error {
+	colIndex, err := columnRefToIndex(opts.Column)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		return err
 	}
 
-	file, err := os.Open(inputPath)
+	file, err := os.Open(opts.InPath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to open input file %q: %v\n", inputPath, err)
-		os.Exit(1)
+		return fmt.Errorf("failed to open input file %q: %w", opts.InPath, err)
 	}
 	defer file.Close()
 
@@ -72,7 +71,7 @@ func main() {
 	reader.FieldsPerRecord = -1
 
 	// Optionally skip header
-	if hasHeader {
+	if opts.Header {
 		_, _ = reader.Read()
 	}
 
@@ -111,10 +110,9 @@ func main() {
 	})
 
 	// Write CSV summary
-	out, err := os.Create(outputPath)
+	out, err := os.Create(opts.OutPath)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to create output file %q: %v\n", outputPath, err)
-		os.Exit(1)
+		return fmt.Errorf("failed to create output file %q: %w", opts.OutPath, err)
 	}
 	defer out.Close()
 
@@ -125,18 +123,17 @@ func main() {
 	}
 	w.Flush()
 	if err := w.Error(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error writing CSV: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("error writing CSV: %w", err)
 	}
 
 	// Also print a quick human-readable summary
-	fmt.Printf("Processed %d rows. Unique groups: %d. Output: %s\n", totalRows, len(summary), outputPath)
+	fmt.Fprintf(stdout, "Processed %d rows. Unique groups: %d. Output: %s\n", totalRows, len(summary), opts.OutPath)
 	for i, item := range summary {
 		if i >= 20 {
-			fmt.Printf("... (%d more)\n", len(summary)-i)
+			fmt.Fprintf(stdout, "... (%d more)\n", len(summary)-i)
 			break
 		}
-		fmt.Printf("%5d  %s\n", item.Count, item.Key)
+		fmt.Fprintf(stdout, "%5d  %s\n", item.Count, item.Key)
 	}
+	return nil
 }
-
@@ -0,0 +1,343 @@
+package photos
+
+import (
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"image"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"isams_to_sheets/src/common"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// AuditOptions configures Audit. Zero thresholds disable their check.
+type AuditOptions struct {
+	// OutPath is the report; .xlsx writes a workbook, anything else CSV.
+	OutPath string
+	// All lists every student, not only those with a reason for a retake.
+	All         bool
+	Concurrency int
+
+	MinWidth  int
+	MinHeight int
+	// MinAspect and MaxAspect bound width/height; ID photos are about 0.75.
+	MinAspect float64
+	MaxAspect float64
+	// MinContrast is the lowest standard deviation of luminance (0-255)
+	// below which a photo is considered blank or near-uniform.
+	MinContrast float64
+	// MinBrightness and MaxBrightness bound the mean luminance (0-255).
+	MinBrightness float64
+	MaxBrightness float64
+	// MinSourceQuality is the lowest estimated JPEG quality of the original.
+	MinSourceQuality int
+}
+
+// DefaultAuditOptions returns the default retake thresholds.
+func DefaultAuditOptions() AuditOptions {
+	return AuditOptions{
+		Concurrency:      common.DefaultPhotoConcurrency,
+		MinWidth:         300,
+		MinHeight:        400,
+		MinAspect:        0.5,
+		MaxAspect:        1.0,
+		MinContrast:      12,
+		MinBrightness:    50,
+		MaxBrightness:    215,
+		MinSourceQuality: 50,
+	}
+}
+
+// AuditResult is one student's line in the audit report.
+type AuditResult struct {
+	Student    common.Student
+	Format     string
+	Width      int
+	Height     int
+	Bytes      int
+	Quality    int // estimated JPEG quality of the original, 0 if unknown
+	Brightness float64
+	Contrast   float64
+	Reasons    []string
+}
+
+// Audit downloads every student's original photo, checks it against the
+// thresholds in opts and writes a report grouped by form group.
+func Audit(isams *common.ISAMSClient, opts AuditOptions) error {
+	students, err := isams.FetchAllStudents()
+	if err != nil {
+		return fmt.Errorf("unable to fetch students: %w", err)
+	}
+
+	results := make([]*AuditResult, len(students))
+	common.RunConcurrent(len(students), opts.Concurrency, "audit", func(i int) {
+		results[i] = auditStudent(isams, students[i], opts)
+	})
+
+	var report []*AuditResult
+	flagged := 0
+	for _, r := range results {
+		if len(r.Reasons) > 0 {
+			flagged++
+		}
+		if opts.All || len(r.Reasons) > 0 {
+			report = append(report, r)
+		}
+	}
+	sort.SliceStable(report, func(i, j int) bool {
+		a, b := report[i].Student, report[j].Student
+		if a.FormGroup != b.FormGroup {
+			return a.FormGroup < b.FormGroup
+		}
+		return a.FullName < b.FullName
+	})
+
+	if strings.EqualFold(filepath.Ext(opts.OutPath), ".xlsx") {
+		err = writeAuditXLSX(opts.OutPath, report)
+	} else {
+		err = writeAuditCSV(opts.OutPath, report)
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Audited %d photos, %d need attention; report written to %s.", len(students), flagged, opts.OutPath)
+	return nil
+}
+
+func auditStudent(isams *common.ISAMSClient, s common.Student, opts AuditOptions) *AuditResult {
+	r := &AuditResult{Student: s}
+	raw, _, err := isams.DownloadStudentPhotoBytes(s.SchoolId)
+	if err != nil {
+		common.Debugf("schoolId %s: %v", s.SchoolId, err)
+		r.Reasons = append(r.Reasons, "no photo")
+		return r
+	}
+	r.Bytes = len(raw)
+	r.Format = common.SniffPhotoFormat(raw)
+	img, err := isams.DecodePhoto(raw)
+	if err != nil {
+		r.Reasons = append(r.Reasons, "unreadable: "+err.Error())
+		return r
+	}
+	b := img.Bounds()
+	r.Width, r.Height = b.Dx(), b.Dy()
+	r.Brightness, r.Contrast = luminanceStats(img)
+	if r.Format == "jpeg" {
+		r.Quality = estimateJPEGQuality(raw)
+	}
+
+	if (opts.MinWidth > 0 && r.Width < opts.MinWidth) || (opts.MinHeight > 0 && r.Height < opts.MinHeight) {
+		r.Reasons = append(r.Reasons, fmt.Sprintf("low resolution %dx%d", r.Width, r.Height))
+	}
+	if r.Height > 0 {
+		aspect := float64(r.Width) / float64(r.Height)
+		if (opts.MinAspect > 0 && aspect < opts.MinAspect) || (opts.MaxAspect > 0 && aspect > opts.MaxAspect) {
+			r.Reasons = append(r.Reasons, fmt.Sprintf("aspect ratio %.2f", aspect))
+		}
+	}
+	if opts.MinContrast > 0 && r.Contrast < opts.MinContrast {
+		r.Reasons = append(r.Reasons, "blank or near-uniform")
+	}
+	if opts.MinBrightness > 0 && r.Brightness < opts.MinBrightness {
+		r.Reasons = append(r.Reasons, "too dark")
+	}
+	if opts.MaxBrightness > 0 && r.Brightness > opts.MaxBrightness {
+		r.Reasons = append(r.Reasons, "overexposed")
+	}
+	if opts.MinSourceQuality > 0 && r.Quality > 0 && r.Quality < opts.MinSourceQuality {
+		r.Reasons = append(r.Reasons, fmt.Sprintf("heavily compressed (quality ~%d)", r.Quality))
+	}
+	return r
+}
+
+// luminanceStats returns the mean and standard deviation of the Rec. 601
+// luma of img, on a 0-255 scale.
+func luminanceStats(img image.Image) (mean, stddev float64) {
+	b := img.Bounds()
+	var sum, sumSq float64
+	n := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			cr, cg, cb, _ := img.At(x, y).RGBA()
+			l := (0.299*float64(cr) + 0.587*float64(cg) + 0.114*float64(cb)) / 257
+			sum += l
+			sumSq += l * l
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	mean = sum / float64(n)
+	return mean, math.Sqrt(math.Max(0, sumSq/float64(n)-mean*mean))
+}
+
+// stdLuminanceQuant is the IJG base luminance quantization table (quality
+// 50), in zig-zag order as stored in DQT segments.
+var stdLuminanceQuant = [64]int{
+	16, 11, 12, 14, 12, 10, 16, 14, 13, 14, 18, 17, 16, 19, 24, 40,
+	26, 24, 22, 22, 24, 49, 35, 37, 29, 40, 58, 51, 61, 60, 57, 51,
+	56, 55, 64, 72, 92, 78, 64, 68, 87, 69, 55, 56, 80, 109, 81, 87,
+	95, 98, 103, 104, 103, 62, 77, 113, 121, 112, 100, 120, 92, 101, 103, 99,
+}
+
+// estimateJPEGQuality estimates the IJG quality a JPEG was saved at from its
+// first luminance quantization table. It returns 0 when none is found.
+func estimateJPEGQuality(b []byte) int {
+	for i := 2; i+4 <= len(b); {
+		if b[i] != 0xFF {
+			return 0
+		}
+		marker := b[i+1]
+		if marker == 0xDA || marker == 0xD9 {
+			return 0
+		}
+		size := int(binary.BigEndian.Uint16(b[i+2:]))
+		if size < 2 || i+2+size > len(b) {
+			return 0
+		}
+		if marker == 0xDB {
+			seg := b[i+4 : i+2+size]
+			// Only 8-bit table 0 (luminance) is compared.
+			if len(seg) >= 65 && seg[0] == 0 {
+				sum, std := 0, 0
+				for k := 0; k < 64; k++ {
+					sum += int(seg[1+k])
+					std += stdLuminanceQuant[k]
+				}
+				scale := float64(sum) * 100 / float64(std)
+				var q float64
+				if scale <= 100 {
+					q = (200 - scale) / 2
+				} else {
+					q = 5000 / scale
+				}
+				return int(math.Round(math.Max(1, math.Min(100, q))))
+			}
+		}
+		i += 2 + size
+	}
+	return 0
+}
+
+var auditHeaders = []string{"FormGroup", "SchoolId", "Name", "YearGroup", "Format", "Width", "Height", "SizeKB", "EstQuality", "Brightness", "Contrast", "Reasons"}
+
+func auditRow(r *AuditResult) []interface{} {
+	return []interface{}{
+		r.Student.FormGroup,
+		r.Student.SchoolId,
+		r.Student.FullName,
+		fmt.Sprintf("%v", r.Student.YearGroup),
+		r.Format,
+		r.Width,
+		r.Height,
+		math.Round(float64(r.Bytes)/102.4) / 10,
+		r.Quality,
+		math.Round(r.Brightness),
+		math.Round(r.Contrast),
+		strings.Join(r.Reasons, "; "),
+	}
+}
+
+func writeAuditCSV(path string, report []*AuditResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create output file: %w", err)
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	if err := w.Write(auditHeaders); err != nil {
+		return err
+	}
+	for _, r := range report {
+		row := auditRow(r)
+		rec := make([]string, len(row))
+		for i, v := range row {
+			rec[i] = fmt.Sprint(v)
+		}
+		if err := w.Write(rec); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// writeAuditXLSX writes a Retakes sheet with a heading row before each form
+// group, and a Summary sheet counting the students listed per form group.
+func writeAuditXLSX(path string, report []*AuditResult) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	const sheet = "Retakes"
+	if err := f.SetSheetName("Sheet1", sheet); err != nil {
+		return err
+	}
+	bold, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+	header := make([]interface{}, len(auditHeaders))
+	for i, h := range auditHeaders {
+		header[i] = h
+	}
+	if err := f.SetSheetRow(sheet, "A1", &header); err != nil {
+		return err
+	}
+	f.SetRowStyle(sheet, 1, 1, bold)
+
+	counts := map[string]int{}
+	var groups []string
+	for _, r := range report {
+		if _, ok := counts[r.Student.FormGroup]; !ok {
+			groups = append(groups, r.Student.FormGroup)
+		}
+		counts[r.Student.FormGroup]++
+	}
+
+	row := 2
+	current := "\x00"
+	for _, r := range report {
+		if r.Student.FormGroup != current {
+			current = r.Student.FormGroup
+			heading := []interface{}{fmt.Sprintf("%s (%d)", formGroupLabel(current), counts[current])}
+			if err := f.SetSheetRow(sheet, fmt.Sprintf("A%d", row), &heading); err != nil {
+				return err
+			}
+			f.SetRowStyle(sheet, row, row, bold)
+			row++
+		}
+		values := auditRow(r)
+		if err := f.SetSheetRow(sheet, fmt.Sprintf("A%d", row), &values); err != nil {
+			return fmt.Errorf("failed to write row %d: %w", row, err)
+		}
+		row++
+	}
+	f.SetColWidth(sheet, "C", "C", 30)
+	f.SetColWidth(sheet, "L", "L", 50)
+
+	const summary = "Summary"
+	if _, err := f.NewSheet(summary); err != nil {
+		return err
+	}
+	f.SetSheetRow(summary, "A1", &[]interface{}{"FormGroup", "Students"})
+	f.SetRowStyle(summary, 1, 1, bold)
+	for i, g := range groups {
+		f.SetSheetRow(summary, fmt.Sprintf("A%d", i+2), &[]interface{}{formGroupLabel(g), counts[g]})
+	}
+	return f.SaveAs(path)
+}
+
+func formGroupLabel(g string) string {
+	if g == "" {
+		return "(no form group)"
+	}
+	return g
+}
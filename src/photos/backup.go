@@ -0,0 +1,249 @@
+package photos
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"isams_to_sheets/src/common"
+)
+
+// Backup entry states, in the order a run writes them.
+const (
+	BackupSaved    = "saved"
+	BackupUploaded = "uploaded"
+	BackupFailed   = "failed"
+)
+
+// BackupStore keeps the original iSAMS photo of every student before a run
+// replaces it. Originals are stored once per SHA-256 under blobs/, and each
+// run has a directory with a manifest.jsonl that records, per student, the
+// original that was saved and whether the replacement upload went through.
+type BackupStore struct {
+	Dir string
+}
+
+// BackupEntry is one manifest line.
+type BackupEntry struct {
+	SchoolId    string `json:"school_id"`
+	State       string `json:"state"`
+	SHA256      string `json:"sha256,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Size        int    `json:"size,omitempty"`
+	// Empty marks a student who had no photo before the run, so there is
+	// no original to restore.
+	Empty bool      `json:"empty,omitempty"`
+	At    time.Time `json:"at"`
+	Error string    `json:"error,omitempty"`
+}
+
+// BackupRun is an open run that entries are appended to.
+type BackupRun struct {
+	ID      string
+	Command string
+
+	store *BackupStore
+	mu    sync.Mutex
+	f     *os.File
+}
+
+// NewBackupStore returns a store rooted at dir.
+func NewBackupStore(dir string) *BackupStore {
+	return &BackupStore{Dir: dir}
+}
+
+func (s *BackupStore) blobPath(sha string) string {
+	return filepath.Join(s.Dir, "blobs", sha)
+}
+
+func (s *BackupStore) runDir(id string) string {
+	return filepath.Join(s.Dir, "runs", id)
+}
+
+// StartRun opens a new run for command. Its ID is the start time to the
+// microsecond, suffixed with the command name; a run started in the same
+// microsecond as another gets a counter before the command, so IDs stay
+// unique and sort by start time.
+func (s *BackupStore) StartRun(command string) (*BackupRun, error) {
+	if err := os.MkdirAll(filepath.Join(s.Dir, "runs"), 0755); err != nil {
+		return nil, err
+	}
+	start := time.Now().Format("20060102-150405.000000")
+	var id, dir string
+	for n := 1; ; n++ {
+		id = start + "-" + command
+		if n > 1 {
+			id = fmt.Sprintf("%s.%d-%s", start, n, command)
+		}
+		dir = s.runDir(id)
+		err := os.Mkdir(dir, 0755)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(s.Dir, "blobs"), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "manifest.jsonl"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &BackupRun{ID: id, Command: command, store: s, f: f}, nil
+}
+
+// Save stores the original photo of schoolId. It must succeed before the
+// photo is replaced.
+func (r *BackupRun) Save(schoolId string, original []byte, contentType string) error {
+	sha := common.PhotoSHA256(original)
+	path := r.store.blobPath(sha)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if err := os.WriteFile(path+".tmp", original, 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			return err
+		}
+	}
+	return r.append(BackupEntry{SchoolId: schoolId, State: BackupSaved, SHA256: sha, ContentType: contentType, Size: len(original)})
+}
+
+// SaveEmpty records that schoolId had no photo before it was replaced.
+func (r *BackupRun) SaveEmpty(schoolId string) error {
+	return r.append(BackupEntry{SchoolId: schoolId, State: BackupSaved, Empty: true})
+}
+
+// Uploaded records that the replacement for schoolId was uploaded.
+func (r *BackupRun) Uploaded(schoolId string) error {
+	return r.append(BackupEntry{SchoolId: schoolId, State: BackupUploaded})
+}
+
+// Failed records that the replacement for schoolId was not uploaded, so the
+// original is still current.
+func (r *BackupRun) Failed(schoolId string, cause error) error {
+	return r.append(BackupEntry{SchoolId: schoolId, State: BackupFailed, Error: cause.Error()})
+}
+
+func (r *BackupRun) append(e BackupEntry) error {
+	e.At = time.Now()
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return r.f.Sync()
+}
+
+// Close closes the run's manifest.
+func (r *BackupRun) Close() error {
+	return r.f.Close()
+}
+
+// Runs lists the run IDs, oldest first.
+func (s *BackupStore) Runs() ([]string, error) {
+	dirs, err := os.ReadDir(filepath.Join(s.Dir, "runs"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, d := range dirs {
+		if d.IsDir() {
+			ids = append(ids, d.Name())
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// RunEntries returns the final state of every student in run id, with the
+// saved original's details carried over. Entries are ordered by school ID.
+func (s *BackupStore) RunEntries(id string) ([]BackupEntry, error) {
+	f, err := os.Open(filepath.Join(s.runDir(id), "manifest.jsonl"))
+	if err != nil {
+		return nil, fmt.Errorf("backup run %s: %w", id, err)
+	}
+	defer f.Close()
+
+	latest := map[string]BackupEntry{}
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var e BackupEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("backup run %s line %d: %w", id, line, err)
+		}
+		if prev, ok := latest[e.SchoolId]; ok && e.SHA256 == "" {
+			e.SHA256, e.ContentType, e.Size, e.Empty = prev.SHA256, prev.ContentType, prev.Size, prev.Empty
+		}
+		latest[e.SchoolId] = e
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	entries := make([]BackupEntry, 0, len(latest))
+	for _, e := range latest {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].SchoolId < entries[j].SchoolId })
+	return entries, nil
+}
+
+// Original returns the stored bytes of a saved entry.
+func (s *BackupStore) Original(e BackupEntry) ([]byte, error) {
+	if e.SHA256 == "" {
+		return nil, fmt.Errorf("no original saved for %s", e.SchoolId)
+	}
+	b, err := os.ReadFile(s.blobPath(e.SHA256))
+	if err != nil {
+		return nil, err
+	}
+	if got := common.PhotoSHA256(b); got != e.SHA256 {
+		return nil, fmt.Errorf("backup of %s is corrupt: sha256 %s, want %s", e.SchoolId, got, e.SHA256)
+	}
+	return b, nil
+}
+
+// LatestEntry returns the most recent saved original of schoolId across all
+// runs, skipping rollback runs so a rollback can be repeated safely. The
+// entry is Empty when the student had no photo before that run.
+func (s *BackupStore) LatestEntry(schoolId string) (BackupEntry, string, error) {
+	runs, err := s.Runs()
+	if err != nil {
+		return BackupEntry{}, "", err
+	}
+	for i := len(runs) - 1; i >= 0; i-- {
+		if strings.HasSuffix(runs[i], "-rollback") {
+			continue
+		}
+		entries, err := s.RunEntries(runs[i])
+		if err != nil {
+			return BackupEntry{}, "", err
+		}
+		for _, e := range entries {
+			if e.SchoolId == schoolId && (e.SHA256 != "" || e.Empty) && e.State != BackupFailed {
+				return e, runs[i], nil
+			}
+		}
+	}
+	return BackupEntry{}, "", fmt.Errorf("no backup found for %s", schoolId)
+}
@@ -0,0 +1,66 @@
+package photos
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestBackupRunEmptyOriginal(t *testing.T) {
+	store := NewBackupStore(t.TempDir())
+	run, err := store.StartRun("import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := run.Save("S1", []byte("original"), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	run.Uploaded("S1")
+	run.SaveEmpty("S2")
+	run.Uploaded("S2")
+	run.SaveEmpty("S3")
+	run.Failed("S3", errors.New("rejected"))
+	run.Close()
+
+	entries, err := store.RunEntries(run.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("entries = %d, want 3", len(entries))
+	}
+	if e := entries[0]; e.Empty || e.SHA256 == "" {
+		t.Errorf("S1 = %+v, want a saved original", e)
+	}
+	if e := entries[1]; !e.Empty || e.State != BackupUploaded {
+		t.Errorf("S2 = %+v, want an uploaded empty original", e)
+	}
+
+	e, id, err := store.LatestEntry("S2")
+	if err != nil || id != run.ID || !e.Empty {
+		t.Errorf("LatestEntry(S2) = %+v, %q, %v, want the empty entry of %s", e, id, err, run.ID)
+	}
+	if _, _, err := store.LatestEntry("S3"); err == nil {
+		t.Error("LatestEntry(S3) found a failed upload")
+	}
+}
+
+func TestStartRunIDsAreUnique(t *testing.T) {
+	store := NewBackupStore(t.TempDir())
+	var ids []string
+	for i := 0; i < 5; i++ {
+		run, err := store.StartRun("fix")
+		if err != nil {
+			t.Fatal(err)
+		}
+		run.Close()
+		ids = append(ids, run.ID)
+	}
+	runs, err := store.Runs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(runs, ids) {
+		t.Errorf("Runs() = %v, want each run once in start order %v", runs, ids)
+	}
+}
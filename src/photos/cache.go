@@ -0,0 +1,93 @@
+package photos
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"time"
+
+	"isams_to_sheets/src/common"
+)
+
+// CacheStats writes a summary of the photo cache to w as text or JSON.
+func CacheStats(w io.Writer, cache *common.PhotoCache, format string) error {
+	stats, err := cache.Stats()
+	if err != nil {
+		return err
+	}
+	if format == common.OutputJSON {
+		return common.WriteJSON(w, stats)
+	}
+	fmt.Fprintf(w, "Cache:        %s\n", stats.Dir)
+	fmt.Fprintf(w, "Entries:      %d\n", stats.Entries)
+	fmt.Fprintf(w, "Originals:    %.1f MB\n", float64(stats.OriginalBytes)/(1024*1024))
+	fmt.Fprintf(w, "Compressed:   %.1f MB\n", float64(stats.CompressedBytes)/(1024*1024))
+	statuses := make([]string, 0, len(stats.ByStatus))
+	for status := range stats.ByStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		fmt.Fprintf(w, "  %-12s%d\n", status+":", stats.ByStatus[status])
+	}
+	if stats.Entries > 0 {
+		fmt.Fprintf(w, "Oldest check: %s\n", stats.OldestCheck.Format(time.RFC3339))
+		fmt.Fprintf(w, "Newest check: %s\n", stats.NewestCheck.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// PurgeOptions configures CachePurge.
+type PurgeOptions struct {
+	// SchoolId, when set, removes only that student's entry.
+	SchoolId string
+	// OlderThan removes entries no sync has confirmed for this long. Zero
+	// removes everything.
+	OlderThan time.Duration
+	DryRun    bool
+}
+
+// CachePurge removes entries from the photo cache.
+func CachePurge(cache *common.PhotoCache, opts PurgeOptions) error {
+	if opts.SchoolId != "" {
+		entry, err := cache.Get(opts.SchoolId)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			return fmt.Errorf("no cached photo for %s", opts.SchoolId)
+		}
+		if opts.DryRun {
+			log.Printf("Would remove cached photo for %s.", opts.SchoolId)
+			return nil
+		}
+		if err := cache.Remove(opts.SchoolId); err != nil {
+			return err
+		}
+		log.Printf("Removed cached photo for %s.", opts.SchoolId)
+		return nil
+	}
+
+	if opts.DryRun {
+		entries, err := cache.Entries()
+		if err != nil {
+			return err
+		}
+		cutoff := time.Now().Add(-opts.OlderThan)
+		n := 0
+		for _, e := range entries {
+			if opts.OlderThan <= 0 || !e.CheckedAt.After(cutoff) {
+				n++
+			}
+		}
+		log.Printf("Would remove %d of %d cached photos.", n, len(entries))
+		return nil
+	}
+	n, err := cache.Evict(opts.OlderThan)
+	if err != nil {
+		return err
+	}
+	log.Printf("Removed %d cached photos from %s.", n, cache.Dir)
+	return nil
+}
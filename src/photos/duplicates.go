@@ -0,0 +1,154 @@
+package photos
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+
+	"isams_to_sheets/src/common"
+)
+
+// DuplicatesOptions configures Duplicates.
+type DuplicatesOptions struct {
+	// MaxDistance is the largest hash distance at which two photos count
+	// as the same; 0 finds only identical photos.
+	MaxDistance int
+	Concurrency int
+	// Output is the format written to the report writer: text or json.
+	Output string
+	// CSVPath, when set, also writes the clusters as CSV.
+	CSVPath string
+}
+
+// DuplicateMember is one student in a duplicate cluster.
+type DuplicateMember struct {
+	SchoolId  string `json:"school_id"`
+	Name      string `json:"name"`
+	FormGroup string `json:"form_group"`
+	// Distance is the hash distance to the first member of the cluster.
+	Distance int `json:"distance"`
+}
+
+// DuplicateCluster is a group of students whose photos look the same.
+type DuplicateCluster struct {
+	Members []DuplicateMember `json:"members"`
+}
+
+// Duplicates fetches every student photo (through the photo cache when one
+// is configured), clusters the students whose photo hashes are within
+// MaxDistance of each other and writes the clusters to w.
+func Duplicates(isams *common.ISAMSClient, w io.Writer, opts DuplicatesOptions) error {
+	students, err := isams.FetchAllStudents()
+	if err != nil {
+		return fmt.Errorf("unable to fetch students: %w", err)
+	}
+	schoolIds := make([]string, len(students))
+	for i, s := range students {
+		schoolIds[i] = s.SchoolId
+	}
+	photos := isams.FetchStudentPhotos(schoolIds, opts.Concurrency)
+
+	var hashed []common.Student
+	var hashes []uint64
+	for _, s := range students {
+		if p := photos[s.SchoolId]; p != nil && p.Decoded() {
+			hashed = append(hashed, s)
+			hashes = append(hashes, p.DHash)
+		}
+	}
+
+	clusters := clusterHashes(hashed, hashes, opts.MaxDistance)
+	log.Printf("Compared %d photos; found %d groups of duplicates.", len(hashed), len(clusters))
+
+	if opts.CSVPath != "" {
+		if err := writeDuplicatesCSV(opts.CSVPath, clusters); err != nil {
+			return err
+		}
+	}
+	if opts.Output == common.OutputJSON {
+		return common.WriteJSON(w, clusters)
+	}
+	for i, c := range clusters {
+		fmt.Fprintf(w, "Group %d (%d students)\n", i+1, len(c.Members))
+		for _, m := range c.Members {
+			fmt.Fprintf(w, "  %-10s %-8s d=%-2d %s\n", m.SchoolId, m.FormGroup, m.Distance, m.Name)
+		}
+	}
+	return nil
+}
+
+// clusterHashes links every pair of students whose hashes are within
+// maxDistance and returns the connected groups of two or more, largest
+// first.
+func clusterHashes(students []common.Student, hashes []uint64, maxDistance int) []DuplicateCluster {
+	parent := make([]int, len(students))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range hashes {
+		for j := i + 1; j < len(hashes); j++ {
+			if common.HashDistance(hashes[i], hashes[j]) <= maxDistance {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	groups := map[int][]int{}
+	var roots []int
+	for i := range students {
+		r := find(i)
+		if _, ok := groups[r]; !ok {
+			roots = append(roots, r)
+		}
+		groups[r] = append(groups[r], i)
+	}
+
+	var clusters []DuplicateCluster
+	for _, r := range roots {
+		idx := groups[r]
+		if len(idx) < 2 {
+			continue
+		}
+		var c DuplicateCluster
+		for _, i := range idx {
+			s := students[i]
+			c.Members = append(c.Members, DuplicateMember{
+				SchoolId:  s.SchoolId,
+				Name:      s.FullName,
+				FormGroup: s.FormGroup,
+				Distance:  common.HashDistance(hashes[idx[0]], hashes[i]),
+			})
+		}
+		clusters = append(clusters, c)
+	}
+	sort.SliceStable(clusters, func(i, j int) bool { return len(clusters[i].Members) > len(clusters[j].Members) })
+	return clusters
+}
+
+func writeDuplicatesCSV(path string, clusters []DuplicateCluster) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create output file: %w", err)
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	w.Write([]string{"Group", "SchoolId", "Name", "FormGroup", "Distance"})
+	for i, c := range clusters {
+		for _, m := range c.Members {
+			w.Write([]string{strconv.Itoa(i + 1), m.SchoolId, m.Name, m.FormGroup, strconv.Itoa(m.Distance)})
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,229 @@
+package photos
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"isams_to_sheets/src/common"
+)
+
+// ExportOptions configures Export. Filters combine: a student must match
+// every filter that is set.
+type ExportOptions struct {
+	// OutPath is the ZIP archive to write.
+	OutPath string
+	// YearGroups and FormGroups limit the export to these groups.
+	YearGroups []string
+	FormGroups []string
+	// IDsPath names a file of school IDs, one per line (the first CSV
+	// column is used); blank lines and lines starting with # are ignored.
+	IDsPath string
+	// CardSources supply the card numbers for the manifest, merged and
+	// written as Cards says.
+	CardSources []common.CardSource
+	Cards       common.CardsConfig
+	Print       common.PrintConfig
+	Concurrency int
+}
+
+// ExportManifest is the name of the manifest inside the archive.
+const ExportManifest = "manifest.csv"
+
+var exportManifestHeaders = []string{"SchoolId", "File", "Name", "FormGroup", "YearGroup", "CardNo", "Width", "Height", "Status"}
+
+type exportResult struct {
+	student common.Student
+	file    string
+	width   int
+	height  int
+	status  string
+}
+
+// Export writes a ZIP of print-resolution student photos named
+// <SchoolId>.jpg, with a manifest listing each selected student's name,
+// form, year and card number. Students without a usable photo stay in the
+// manifest with the reason as their status.
+func Export(isams *common.ISAMSClient, opts ExportOptions) error {
+	students, err := isams.FetchAllStudents()
+	if err != nil {
+		return fmt.Errorf("unable to fetch students: %w", err)
+	}
+	students, err = filterExport(students, opts)
+	if err != nil {
+		return err
+	}
+	if len(students) == 0 {
+		return fmt.Errorf("no students match the filters")
+	}
+	cards, err := common.LoadCards(opts.CardSources, opts.Cards)
+	if err != nil {
+		common.Warnf("could not load card numbers, the manifest will have none: %v", err)
+		cards = common.NewCardRegistry(nil, nil)
+	}
+
+	tmp := opts.OutPath + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("unable to create output file: %w", err)
+	}
+	defer os.Remove(tmp)
+	defer f.Close()
+	zw := zip.NewWriter(f)
+
+	// Photos are written as they are encoded, so only the ones in flight
+	// are held in memory.
+	var mu sync.Mutex
+	results := make([]exportResult, len(students))
+	common.RunConcurrent(len(students), opts.Concurrency, "export", func(i int) {
+		s := students[i]
+		r := exportResult{student: s}
+		defer func() { results[i] = r }()
+
+		raw, _, err := isams.DownloadStudentPhotoBytes(s.SchoolId)
+		if err != nil {
+			common.Debugf("%s: %v", s.SchoolId, err)
+			r.status = "no photo"
+			return
+		}
+		img, err := isams.DecodePhoto(raw)
+		if err != nil {
+			r.status = "unreadable: " + err.Error()
+			return
+		}
+		res, err := common.EncodePrintPhoto(img, opts.Print.MaxWidth, opts.Print.MaxHeight, opts.Print.Quality)
+		if err != nil {
+			r.status = "encode error: " + err.Error()
+			return
+		}
+
+		name := s.SchoolId + ".jpg"
+		mu.Lock()
+		defer mu.Unlock()
+		// JPEG does not deflate further, so entries are stored.
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: time.Now()})
+		if err == nil {
+			_, err = w.Write(res.JPEG)
+		}
+		if err != nil {
+			r.status = "write error: " + err.Error()
+			return
+		}
+		r.file, r.width, r.height, r.status = name, res.Width, res.Height, "ok"
+	})
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i].student, results[j].student
+		if a.FormGroup != b.FormGroup {
+			return a.FormGroup < b.FormGroup
+		}
+		return a.FullName < b.FullName
+	})
+	w, err := zw.Create(ExportManifest)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(exportManifestHeaders)
+	exported := 0
+	for _, r := range results {
+		s := r.student
+		row := []string{s.SchoolId, r.file, s.FullName, s.FormGroup, fmt.Sprintf("%v", s.YearGroup), cards.Card(s.SchoolId), "", "", r.status}
+		if r.file != "" {
+			row[6], row[7] = strconv.Itoa(r.width), strconv.Itoa(r.height)
+			exported++
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, opts.OutPath); err != nil {
+		return err
+	}
+	log.Printf("Exported %d of %d photos to %s.", exported, len(students), opts.OutPath)
+	return nil
+}
+
+// filterExport keeps the students that match every filter in opts.
+func filterExport(students []common.Student, opts ExportOptions) ([]common.Student, error) {
+	var ids map[string]bool
+	if opts.IDsPath != "" {
+		var err error
+		if ids, err = readIDList(opts.IDsPath); err != nil {
+			return nil, err
+		}
+	}
+	years := lowerSet(opts.YearGroups)
+	forms := lowerSet(opts.FormGroups)
+
+	var out []common.Student
+	for _, s := range students {
+		if ids != nil && !ids[s.SchoolId] {
+			continue
+		}
+		if len(years) > 0 && !years[strings.ToLower(fmt.Sprintf("%v", s.YearGroup))] {
+			continue
+		}
+		if len(forms) > 0 && !forms[strings.ToLower(s.FormGroup)] {
+			continue
+		}
+		out = append(out, s)
+	}
+	if ids != nil {
+		found := map[string]bool{}
+		for _, s := range out {
+			found[s.SchoolId] = true
+		}
+		for id := range ids {
+			if !found[id] {
+				common.Warnf("%s is in %s but not an iSAMS student matching the filters", id, opts.IDsPath)
+			}
+		}
+	}
+	return out, nil
+}
+
+func readIDList(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %w", path, err)
+	}
+	defer f.Close()
+	ids := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id := strings.TrimSpace(strings.Split(line, ",")[0])
+		ids[id] = true
+	}
+	return ids, scanner.Err()
+}
+
+func lowerSet(values []string) map[string]bool {
+	set := map[string]bool{}
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			set[strings.ToLower(v)] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,347 @@
+package photos
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"isams_to_sheets/src/common"
+)
+
+// FixOptions configures Fix.
+type FixOptions struct {
+	// ListPath is a CSV written by Scan; only its first column is used.
+	ListPath string
+	// DryRun compresses the photos but does not upload them.
+	DryRun bool
+	// Backups receives every original before it is replaced. It is
+	// required unless DryRun is set.
+	Backups *BackupStore
+	// ReportPath, when set, receives a CSV line per student with the
+	// outcome of the upload and whether it was verified.
+	ReportPath string
+	// JournalPath records each student's outcome so a re-run skips the
+	// photos already fixed; empty uses ListPath with ".journal" appended.
+	// Dry runs read it but never write it.
+	JournalPath string
+	// MaxAttempts is how many failed runs a student gets before it is left
+	// for manual attention.
+	MaxAttempts int
+}
+
+// DefaultFixMaxAttempts is the MaxAttempts used when none is given.
+const DefaultFixMaxAttempts = 3
+
+// Verification states in the fix report.
+const (
+	Verified   = "verified"
+	Unverified = "unverified"
+)
+
+var fixReportHeaders = []string{"SchoolId", "Result", "OriginalBytes", "UploadedBytes", "Quality", "Width", "Height", "Verified", "Detail"}
+
+// fixReport writes the fix run report line by line, so an interrupted run
+// still leaves a report of what was done.
+type fixReport struct {
+	f *os.File
+	w *csv.Writer
+}
+
+func newFixReport(path string) (*fixReport, error) {
+	if path == "" {
+		return &fixReport{}, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create report: %w", err)
+	}
+	r := &fixReport{f: f, w: csv.NewWriter(f)}
+	r.w.Write(fixReportHeaders)
+	return r, nil
+}
+
+func (r *fixReport) add(schoolId, result string, current *currentPhoto, verified, detail string) {
+	if r.w == nil {
+		return
+	}
+	row := []string{schoolId, result, "", "", "", "", "", verified, detail}
+	if current != nil {
+		res := current.Compressed
+		row[2] = strconv.Itoa(len(current.Original))
+		row[3] = strconv.Itoa(len(res.JPEG))
+		row[4] = strconv.Itoa(res.Quality)
+		row[5] = strconv.Itoa(res.Width)
+		row[6] = strconv.Itoa(res.Height)
+	}
+	r.w.Write(row)
+	r.w.Flush()
+}
+
+func (r *fixReport) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	r.w.Flush()
+	if err := r.w.Error(); err != nil {
+		r.f.Close()
+		return err
+	}
+	return r.f.Close()
+}
+
+// currentPhoto is a student's current iSAMS photo with its compressed
+// replacement.
+type currentPhoto struct {
+	Original    []byte
+	ContentType string
+	Compressed  *common.CompressResult
+}
+
+// compressFromCurrent fetches the current photo and compresses it with the
+// client's compressor, the same one FetchStudentPhoto uses.
+func compressFromCurrent(isams *common.ISAMSClient, schoolId string) (*currentPhoto, error) {
+	raw, ct, err := isams.DownloadStudentPhotoBytes(schoolId)
+	if err != nil {
+		return nil, fmt.Errorf("download photo failed: %v", err)
+	}
+
+	img, err := isams.DecodePhoto(raw)
+	if err != nil {
+		return nil, fmt.Errorf("decode failed (%s): %v", ct, err)
+	}
+
+	res, err := isams.CompressPhoto(img)
+	if err != nil {
+		return nil, fmt.Errorf("compress failed: %v", err)
+	}
+	return &currentPhoto{Original: raw, ContentType: ct, Compressed: res}, nil
+}
+
+// Fix recompresses every photo listed in ListPath and uploads the smaller
+// copy back to iSAMS as the student's current photo. Each original is saved
+// to the backup store first, so Rollback can restore it. Uploads are read
+// back and the report marks each as verified or unverified.
+//
+// Outcomes are appended to the journal as they happen. Students the journal
+// shows as done are skipped, and failed ones are retried until they have
+// failed MaxAttempts times.
+func Fix(isams *common.ISAMSClient, opts FixOptions) error {
+	schoolIds, err := readFixList(opts.ListPath)
+	if err != nil {
+		return err
+	}
+	if opts.JournalPath == "" {
+		opts.JournalPath = opts.ListPath + ".journal"
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultFixMaxAttempts
+	}
+	openJournal := OpenJournal
+	if opts.DryRun {
+		openJournal = ReadJournal
+	}
+	journal, err := openJournal(opts.JournalPath)
+	if err != nil {
+		return fmt.Errorf("unable to open journal: %w", err)
+	}
+	defer journal.Close()
+
+	report, err := newFixReport(opts.ReportPath)
+	if err != nil {
+		return err
+	}
+	defer report.Close()
+
+	var run *BackupRun
+	if !opts.DryRun {
+		if opts.Backups == nil {
+			return fmt.Errorf("a backup store is required to replace photos")
+		}
+		run, err = opts.Backups.StartRun("fix")
+		if err != nil {
+			return fmt.Errorf("unable to start backup run: %w", err)
+		}
+		defer run.Close()
+		log.Printf("Backing up originals as run %s.", run.ID)
+	}
+
+	skipped, fixed, failed := 0, 0, 0
+	var gaveUp []string
+	for _, schoolId := range schoolIds {
+		if journal.Done(schoolId) {
+			skipped++
+			continue
+		}
+		if n, _ := journal.Failures(schoolId); n >= opts.MaxAttempts {
+			gaveUp = append(gaveUp, schoolId)
+			continue
+		}
+
+		var e JournalEntry
+		if upload, ok := journal.Unverified(schoolId); ok {
+			e = verifyStudent(isams, schoolId, upload, run, report)
+		} else {
+			e = fixStudent(isams, schoolId, run, report)
+		}
+		if opts.DryRun {
+			continue
+		}
+		if err := journal.Record(e); err != nil {
+			return fmt.Errorf("unable to write journal: %w", err)
+		}
+		if e.Outcome == JournalDone {
+			fixed++
+		} else {
+			failed++
+		}
+	}
+
+	if opts.ReportPath != "" {
+		log.Printf("Run report written to %s.", opts.ReportPath)
+	}
+	if opts.DryRun {
+		log.Printf("%d of %d photos already fixed, %d at the attempt limit (dry run).", skipped, len(schoolIds), len(gaveUp))
+		return report.Close()
+	}
+	log.Printf("Fixed %d photos this run, %d already fixed earlier, %d failed.", fixed, skipped, failed)
+	retry := 0
+	for _, id := range schoolIds {
+		if journal.Done(id) {
+			continue
+		}
+		if n, lastErr := journal.Failures(id); n >= opts.MaxAttempts {
+			log.Printf("%s: gave up after %d attempts: %s", id, n, lastErr)
+		} else {
+			retry++
+		}
+	}
+	if retry > 0 {
+		log.Printf("%d photos left to retry; re-run to continue (journal: %s).", retry, opts.JournalPath)
+	}
+	return report.Close()
+}
+
+// readFixList returns the school IDs in the first column of a Scan CSV.
+func readFixList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var schoolIds []string
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNo++
+		if lineNo == 1 {
+			// skip header
+			continue
+		}
+		parts := strings.Split(line, ",")
+		if len(parts) == 0 {
+			continue
+		}
+		if schoolId := strings.TrimSpace(parts[0]); schoolId != "" {
+			schoolIds = append(schoolIds, schoolId)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan error: %w", err)
+	}
+	return schoolIds, nil
+}
+
+// fixStudent recompresses and uploads one photo, and returns its journal
+// entry. run is nil on a dry run.
+func fixStudent(isams *common.ISAMSClient, schoolId string, run *BackupRun, report *fixReport) JournalEntry {
+	current, err := compressFromCurrent(isams, schoolId)
+	if err != nil {
+		log.Printf("%s: compress error: %v", schoolId, err)
+		report.add(schoolId, "compress error", nil, "", err.Error())
+		return JournalEntry{SchoolId: schoolId, Outcome: JournalFailed, Error: err.Error()}
+	}
+	res := current.Compressed
+
+	if run == nil {
+		log.Printf("%s: would upload %d bytes at quality %d, %dx%d (dry run)", schoolId, len(res.JPEG), res.Quality, res.Width, res.Height)
+		report.add(schoolId, "dry run", current, "", "")
+		return JournalEntry{SchoolId: schoolId}
+	}
+	if err := run.Save(schoolId, current.Original, current.ContentType); err != nil {
+		log.Printf("%s: backup failed, not uploading: %v", schoolId, err)
+		report.add(schoolId, "backup failed", current, "", err.Error())
+		return JournalEntry{SchoolId: schoolId, Outcome: JournalFailed, Error: err.Error()}
+	}
+	err = isams.UploadStudentPhoto(schoolId, res.JPEG)
+	var unverified *common.UnverifiedPhotoError
+	switch {
+	case errors.As(err, &unverified):
+		// iSAMS accepted the upload, so the original may have been
+		// replaced and stays eligible for rollback.
+		log.Printf("%s: uploaded but not verified: %s", schoolId, unverified.Reason)
+		if err := run.Uploaded(schoolId); err != nil {
+			common.Warnf("%s: could not record upload: %v", schoolId, err)
+		}
+		report.add(schoolId, "uploaded", current, Unverified, unverified.Reason)
+		e := JournalEntry{SchoolId: schoolId, Outcome: JournalUnverified, Error: unverified.Reason, OriginalSHA256: common.PhotoSHA256(current.Original)}
+		fp, err := isams.FingerprintPhoto(res.JPEG)
+		if err != nil {
+			common.Warnf("%s: could not fingerprint the upload, a re-run can only match its exact bytes: %v", schoolId, err)
+		}
+		e.SHA256, e.Width, e.Height, e.DHash = fp.SHA256, fp.Width, fp.Height, fp.DHash
+		return e
+	case err != nil:
+		log.Printf("%s: upload failed: %v", schoolId, err)
+		if err := run.Failed(schoolId, err); err != nil {
+			common.Warnf("%s: could not record failed upload: %v", schoolId, err)
+		}
+		report.add(schoolId, "upload failed", current, "", err.Error())
+		return JournalEntry{SchoolId: schoolId, Outcome: JournalFailed, Error: err.Error()}
+	}
+	if err := run.Uploaded(schoolId); err != nil {
+		common.Warnf("%s: could not record upload: %v", schoolId, err)
+	}
+	log.Printf("%s: upload ok (%d bytes at quality %d, %dx%d)", schoolId, len(res.JPEG), res.Quality, res.Width, res.Height)
+	report.add(schoolId, "uploaded", current, Verified, "")
+	return JournalEntry{SchoolId: schoolId, Outcome: JournalDone}
+}
+
+// verifyStudent checks an upload an earlier run could not verify, recorded
+// in upload, against the current photo the way the upload itself is
+// verified, so a re-encoded copy counts. It does not back up or upload again
+// while the current photo may be the upload; only when iSAMS still serves
+// the original that upload replaced is the student fixed again.
+func verifyStudent(isams *common.ISAMSClient, schoolId string, upload JournalEntry, run *BackupRun, report *fixReport) JournalEntry {
+	unverified := func(reason string) JournalEntry {
+		log.Printf("%s: still unverified: %s", schoolId, reason)
+		report.add(schoolId, "verify only", nil, Unverified, reason)
+		e := upload
+		e.Error = reason
+		return e
+	}
+	current, _, err := isams.DownloadStudentPhotoBytes(schoolId)
+	if err != nil {
+		return unverified(fmt.Sprintf("could not read back: %v", err))
+	}
+	if upload.OriginalSHA256 != "" && common.PhotoSHA256(current) == upload.OriginalSHA256 {
+		log.Printf("%s: iSAMS still serves the original, uploading again", schoolId)
+		return fixStudent(isams, schoolId, run, report)
+	}
+	if upload.SHA256 == "" {
+		return unverified("the journal has no record of the upload; check the photo by hand")
+	}
+	var mismatch *common.UnverifiedPhotoError
+	if err := isams.MatchStudentPhoto(schoolId, current, upload.upload()); errors.As(err, &mismatch) {
+		return unverified(mismatch.Reason)
+	}
+	log.Printf("%s: earlier upload verified", schoolId)
+	report.add(schoolId, "verify only", nil, Verified, "")
+	return JournalEntry{SchoolId: schoolId, Outcome: JournalDone}
+}
@@ -0,0 +1,458 @@
+package photos
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"isams_to_sheets/src/common"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ImportOptions configures Import.
+type ImportOptions struct {
+	// Dir holds the photographer's files; subfolders are not searched.
+	Dir string
+	// MinScore is the lowest name similarity (0-1) accepted as a match.
+	MinScore float64
+	// ReportPath receives a CSV line per file with its match and outcome.
+	ReportPath string
+	// Print is the size and quality the photos are uploaded at.
+	Print common.PrintConfig
+	// Backups receives each student's current photo before it is
+	// replaced. It is required unless DryRun is set.
+	Backups *BackupStore
+	// DryRun prints the matches and writes the report without uploading.
+	DryRun bool
+}
+
+// DefaultImportMinScore accepts names that differ by a typo or a missing
+// middle name but not different people with a shared surname.
+const DefaultImportMinScore = 0.85
+
+// importAmbiguityGap is how far ahead of the runner-up a name match must be.
+const importAmbiguityGap = 0.05
+
+// Import match kinds.
+const (
+	MatchByID   = "id"
+	MatchByName = "name"
+)
+
+// ImportMatch is the student a file was matched to, if any.
+type ImportMatch struct {
+	File    string
+	Student *common.Student
+	How     string
+	Score   float64
+	// Problem explains why a file was not matched or will not be uploaded.
+	Problem string
+}
+
+// Import matches every image in Dir to a student, by a school ID in the
+// filename or by a fuzzy match of the filename against student names,
+// prints the matches, and uploads each matched photo as the student's
+// current photo. Images go through the shared decoder, so they are
+// oriented and flattened, and are uploaded at the print size and quality.
+// Files that match no student, match several, or match a student another
+// file also matched are not uploaded and are listed in the report.
+func Import(isams *common.ISAMSClient, w io.Writer, opts ImportOptions) error {
+	if opts.MinScore <= 0 {
+		opts.MinScore = DefaultImportMinScore
+	}
+	files, err := importFiles(opts.Dir)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no image files in %s", opts.Dir)
+	}
+	students, err := isams.FetchAllStudents()
+	if err != nil {
+		return fmt.Errorf("unable to fetch students: %w", err)
+	}
+
+	matches := matchImportFiles(files, students, opts.MinScore)
+	writeImportPreview(w, matches)
+
+	report, err := newImportReport(opts.ReportPath)
+	if err != nil {
+		return err
+	}
+	defer report.Close()
+
+	var run *BackupRun
+	if !opts.DryRun {
+		if opts.Backups == nil {
+			return fmt.Errorf("a backup store is required to replace photos")
+		}
+		run, err = opts.Backups.StartRun("import")
+		if err != nil {
+			return fmt.Errorf("unable to start backup run: %w", err)
+		}
+		defer run.Close()
+	}
+
+	uploaded, unmatched, failed := 0, 0, 0
+	for _, m := range matches {
+		if m.Student == nil || m.Problem != "" {
+			unmatched++
+			report.add(m, "not matched", m.Problem)
+			continue
+		}
+		if opts.DryRun {
+			report.add(m, "dry run", "")
+			continue
+		}
+		if err := importPhoto(isams, run, m, opts.Print); err != nil {
+			log.Printf("%s: %v", m.File, err)
+			failed++
+			var unverified *common.UnverifiedPhotoError
+			if errors.As(err, &unverified) {
+				report.add(m, Unverified, unverified.Reason)
+			} else {
+				report.add(m, "failed", err.Error())
+			}
+			continue
+		}
+		uploaded++
+		report.add(m, "uploaded", "")
+	}
+	if err := report.Close(); err != nil {
+		return err
+	}
+
+	if opts.DryRun {
+		log.Printf("%d of %d files matched, %d not matched (dry run).", len(matches)-unmatched, len(matches), unmatched)
+	} else {
+		log.Printf("Uploaded %d photos, %d failed, %d files not matched; originals are in backup run %s.", uploaded, failed, unmatched, run.ID)
+	}
+	if opts.ReportPath != "" {
+		log.Printf("Report written to %s.", opts.ReportPath)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d photos could not be uploaded", failed)
+	}
+	return nil
+}
+
+// importPhoto backs up the student's current photo, if any, then uploads the
+// file in its place.
+func importPhoto(isams *common.ISAMSClient, run *BackupRun, m *ImportMatch, print common.PrintConfig) error {
+	raw, err := os.ReadFile(m.File)
+	if err != nil {
+		return err
+	}
+	img, err := isams.DecodePhoto(raw)
+	if err != nil {
+		return err
+	}
+	res, err := common.EncodePrintPhoto(img, print.MaxWidth, print.MaxHeight, print.Quality)
+	if err != nil {
+		return err
+	}
+
+	schoolId := m.Student.SchoolId
+	current, ct, err := isams.DownloadStudentPhotoBytes(schoolId)
+	switch {
+	case errors.Is(err, common.ErrNoStudentPhoto):
+		// Recorded so rollback knows there is nothing to restore.
+		if err := run.SaveEmpty(schoolId); err != nil {
+			return fmt.Errorf("could not record the missing photo, not uploading: %w", err)
+		}
+	case err != nil:
+		return fmt.Errorf("could not back up the current photo, not uploading: %w", err)
+	default:
+		if err := run.Save(schoolId, current, ct); err != nil {
+			return fmt.Errorf("could not back up the current photo, not uploading: %w", err)
+		}
+	}
+
+	err = isams.UploadStudentPhoto(schoolId, res.JPEG)
+	var unverified *common.UnverifiedPhotoError
+	switch {
+	case errors.As(err, &unverified):
+		if err := run.Uploaded(schoolId); err != nil {
+			common.Warnf("%s: could not record upload: %v", schoolId, err)
+		}
+		return err
+	case err != nil:
+		if err := run.Failed(schoolId, err); err != nil {
+			common.Warnf("%s: could not record failed upload: %v", schoolId, err)
+		}
+		return err
+	}
+	if err := run.Uploaded(schoolId); err != nil {
+		common.Warnf("%s: could not record upload: %v", schoolId, err)
+	}
+	log.Printf("%s: uploaded for %s %s (%dx%d)", filepath.Base(m.File), schoolId, m.Student.FullName, res.Width, res.Height)
+	return nil
+}
+
+// importFiles lists the regular, non-hidden image files in dir, sorted by
+// name. Files are recognised by their first bytes, so notes and Thumbs.db
+// are skipped whatever they are called.
+func importFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files, skipped []string
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		head, err := readHead(path, 16)
+		if err != nil {
+			return nil, err
+		}
+		if common.SniffPhotoFormat(head) == "" {
+			skipped = append(skipped, e.Name())
+			continue
+		}
+		files = append(files, path)
+	}
+	if len(skipped) > 0 {
+		sort.Strings(skipped)
+		log.Printf("Skipping %d files that are not images: %s", len(skipped), strings.Join(skipped, ", "))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// readHead returns up to n bytes from the start of the file at path.
+func readHead(path string, n int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	head := make([]byte, n)
+	read, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return head[:read], nil
+}
+
+// matchImportFiles matches each file to a student. A token of the filename
+// equal to a school ID wins; otherwise the filename is compared with every
+// student name and the best match is kept if it scores at least minScore
+// and is clearly ahead of the runner-up. Students matched by more than one
+// file are flagged on all of them.
+func matchImportFiles(files []string, students []common.Student, minScore float64) []*ImportMatch {
+	byID := map[string]*common.Student{}
+	names := make([][]string, len(students))
+	for i := range students {
+		s := &students[i]
+		byID[strings.ToLower(s.SchoolId)] = s
+		names[i] = nameTokens(s.FullName)
+	}
+
+	matches := make([]*ImportMatch, len(files))
+	for i, file := range files {
+		m := &ImportMatch{File: file}
+		matches[i] = m
+		stem := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+
+		for _, tok := range strings.FieldsFunc(stem, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			if s, ok := byID[strings.ToLower(tok)]; ok {
+				m.Student, m.How, m.Score = s, MatchByID, 1
+				break
+			}
+		}
+		if m.Student != nil {
+			continue
+		}
+
+		tokens := nameTokens(stem)
+		if len(tokens) == 0 {
+			m.Problem = "no school ID or name in the filename"
+			continue
+		}
+		best, second := -1, 0.0
+		bestScore := 0.0
+		for j := range students {
+			score := nameScore(tokens, names[j])
+			if score > bestScore {
+				second, best, bestScore = bestScore, j, score
+			} else if score > second {
+				second = score
+			}
+		}
+		switch {
+		case best < 0 || bestScore < minScore:
+			m.Problem = "no student name is close enough"
+			if best >= 0 {
+				m.Problem += fmt.Sprintf(" (closest: %s, %.2f)", students[best].FullName, bestScore)
+			}
+		case bestScore-second < importAmbiguityGap:
+			m.Problem = fmt.Sprintf("ambiguous: several students score %.2f", bestScore)
+		default:
+			m.Student, m.How, m.Score = &students[best], MatchByName, bestScore
+		}
+	}
+
+	claimed := map[string][]*ImportMatch{}
+	for _, m := range matches {
+		if m.Student != nil {
+			claimed[m.Student.SchoolId] = append(claimed[m.Student.SchoolId], m)
+		}
+	}
+	for _, ms := range claimed {
+		if len(ms) < 2 {
+			continue
+		}
+		for _, m := range ms {
+			m.Problem = fmt.Sprintf("%d files match %s", len(ms), m.Student.SchoolId)
+		}
+	}
+	return matches
+}
+
+// nameTokens lowercases s, strips accents and splits it into words of
+// letters, so "Smith, José-Luis" becomes [smith jose luis].
+func nameTokens(s string) []string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// combining accent
+		case unicode.IsLetter(r):
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Fields(b.String())
+}
+
+// nameScore compares two names as unordered sets of words, so "Smith John"
+// matches "John Smith". Every word of the shorter name is paired with its
+// most similar word in the longer one; words of the longer name left over,
+// such as a middle name, cost a little. The result is between 0 and 1.
+func nameScore(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	used := make([]bool, len(b))
+	total := 0.0
+	for _, wa := range a {
+		bestJ, best := -1, 0.0
+		for j, wb := range b {
+			if used[j] {
+				continue
+			}
+			if s := similarity(wa, wb); s > best {
+				bestJ, best = j, s
+			}
+		}
+		if bestJ >= 0 {
+			used[bestJ] = true
+		}
+		total += best
+	}
+	score := total / float64(len(a))
+	// A single shared word, such as a surname alone, is not enough.
+	if len(a) == 1 {
+		score *= 0.8
+	}
+	return score * (1 - 0.03*float64(len(b)-len(a)))
+}
+
+// similarity is 1 minus the Levenshtein distance over the longer length.
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return 1 - float64(prev[len(rb)])/float64(max(len(ra), len(rb)))
+}
+
+func writeImportPreview(w io.Writer, matches []*ImportMatch) {
+	for _, m := range matches {
+		file := filepath.Base(m.File)
+		switch {
+		case m.Student == nil:
+			fmt.Fprintf(w, "  %-30s -> (no match) %s\n", file, m.Problem)
+		case m.Problem != "":
+			fmt.Fprintf(w, "  %-30s -> %-10s %s (%s) SKIPPED: %s\n", file, m.Student.SchoolId, m.Student.FullName, m.Student.FormGroup, m.Problem)
+		default:
+			fmt.Fprintf(w, "  %-30s -> %-10s %s (%s) [%s %.2f]\n", file, m.Student.SchoolId, m.Student.FullName, m.Student.FormGroup, m.How, m.Score)
+		}
+	}
+}
+
+var importReportHeaders = []string{"File", "SchoolId", "Name", "FormGroup", "MatchedBy", "Score", "Result", "Detail"}
+
+type importReport struct {
+	f *os.File
+	w *csv.Writer
+}
+
+func newImportReport(path string) (*importReport, error) {
+	if path == "" {
+		return &importReport{}, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create report: %w", err)
+	}
+	r := &importReport{f: f, w: csv.NewWriter(f)}
+	r.w.Write(importReportHeaders)
+	return r, nil
+}
+
+func (r *importReport) add(m *ImportMatch, result, detail string) {
+	if r.w == nil {
+		return
+	}
+	row := []string{filepath.Base(m.File), "", "", "", "", "", result, detail}
+	if m.Student != nil {
+		row[1], row[2], row[3] = m.Student.SchoolId, m.Student.FullName, m.Student.FormGroup
+		row[4], row[5] = m.How, fmt.Sprintf("%.2f", m.Score)
+	}
+	r.w.Write(row)
+	r.w.Flush()
+}
+
+func (r *importReport) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	r.w.Flush()
+	err := r.w.Error()
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	r.f = nil
+	return err
+}
@@ -0,0 +1,86 @@
+package photos
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"isams_to_sheets/src/common"
+)
+
+func TestImportFilesSkipsNonImages(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"smith_john.jpg": {0xff, 0xd8, 0xff, 0xe0, 0, 0x10},
+		"jones.png":      []byte("\x89PNG\r\n\x1a\n"),
+		"notes.txt":      []byte("retakes on Friday"),
+		"Thumbs.db":      {0xd0, 0xcf, 0x11, 0xe0},
+		"empty.jpg":      nil,
+		".DS_Store":      {0xff, 0xd8, 0xff},
+	}
+	for name, b := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := importFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "jones.png"), filepath.Join(dir, "smith_john.jpg")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("importFiles = %v, want %v", got, want)
+	}
+}
+
+func TestMatchImportFiles(t *testing.T) {
+	students := []common.Student{
+		{SchoolId: "S100", FullName: "John Smith"},
+		{SchoolId: "S101", FullName: "Zoë Anne Brown"},
+		{SchoolId: "S102", FullName: "Mary Smith"},
+		{SchoolId: "S103", FullName: "Jon Smyth"},
+		{SchoolId: "S104", FullName: "Jonathan Smith"},
+	}
+	tests := []struct {
+		file    string
+		want    string
+		how     string
+		problem bool
+	}{
+		{file: "IMG_S102.jpg", want: "S102", how: MatchByID},
+		{file: "brown, zoe.jpg", want: "S101", how: MatchByName},
+		{file: "Smith Mary.png", want: "S102", how: MatchByName},
+		{file: "smith.jpg", problem: true},
+		{file: "John Smith.jpg", want: "S100", how: MatchByName},
+		{file: "Jon Smith.jpg", problem: true}, // John Smith and Jon Smyth score too close
+		{file: "DSC_0001.jpg", problem: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			m := matchImportFiles([]string{tt.file}, students, DefaultImportMinScore)[0]
+			if tt.problem {
+				if m.Problem == "" {
+					t.Errorf("matched %s (%.2f), want no match", m.Student.SchoolId, m.Score)
+				}
+				return
+			}
+			if m.Problem != "" || m.Student == nil {
+				t.Fatalf("no match: %s", m.Problem)
+			}
+			if m.Student.SchoolId != tt.want || m.How != tt.how {
+				t.Errorf("matched %s by %s, want %s by %s", m.Student.SchoolId, m.How, tt.want, tt.how)
+			}
+		})
+	}
+}
+
+func TestMatchImportFilesFlagsSharedStudent(t *testing.T) {
+	students := []common.Student{{SchoolId: "S100", FullName: "John Smith"}}
+	matches := matchImportFiles([]string{"S100.jpg", "S100 retake.jpg"}, students, DefaultImportMinScore)
+	for _, m := range matches {
+		if m.Problem == "" {
+			t.Errorf("%s: want a problem, two files match S100", m.File)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package photos
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"isams_to_sheets/src/common"
+)
+
+// Journal outcomes. Only JournalDone is final; the others are retried until
+// a student has failed MaxAttempts times.
+const (
+	JournalDone       = "done"
+	JournalUnverified = "unverified"
+	JournalFailed     = "failed"
+)
+
+// JournalEntry is one line of a fix journal.
+type JournalEntry struct {
+	SchoolId string `json:"school_id"`
+	Outcome  string `json:"outcome"`
+	Error    string `json:"error,omitempty"`
+	// SHA256, Width, Height and DHash fingerprint the uploaded photo, and
+	// OriginalSHA256 the photo it replaced. They are recorded with
+	// JournalUnverified so a later run can check the upload without
+	// sending it again, or send it again when the original is still there.
+	SHA256         string    `json:"sha256,omitempty"`
+	Width          int       `json:"width,omitempty"`
+	Height         int       `json:"height,omitempty"`
+	DHash          uint64    `json:"dhash,omitempty"`
+	OriginalSHA256 string    `json:"original_sha256,omitempty"`
+	At             time.Time `json:"at"`
+}
+
+// upload returns the fingerprint of the photo e records as uploaded.
+func (e JournalEntry) upload() common.PhotoFingerprint {
+	return common.PhotoFingerprint{SHA256: e.SHA256, Width: e.Width, Height: e.Height, DHash: e.DHash}
+}
+
+// Journal is an append-only record of each student's outcome across fix
+// runs over the same list, so an interrupted run can resume where it
+// stopped.
+type Journal struct {
+	Path string
+
+	f          *os.File
+	done       map[string]bool
+	failures   map[string]int
+	lastErr    map[string]string
+	unverified map[string]JournalEntry
+}
+
+// ReadJournal reads the journal at path, if any, without opening it for
+// writing; Record then fails. Unreadable lines, left by a crash mid-write,
+// are ignored.
+func ReadJournal(path string) (*Journal, error) {
+	j := &Journal{Path: path, done: map[string]bool{}, failures: map[string]int{}, lastErr: map[string]string{}, unverified: map[string]JournalEntry{}}
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// OpenJournal reads the journal at path and opens it for appending.
+func OpenJournal(path string) (*Journal, error) {
+	j, err := ReadJournal(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	j.f = f
+	// Start on a fresh line after a torn write.
+	if st, err := f.Stat(); err == nil && st.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, st.Size()-1); err == nil && last[0] != '\n' {
+			if _, err := f.Write([]byte{'\n'}); err != nil {
+				f.Close()
+				return nil, err
+			}
+		}
+	}
+	return j, nil
+}
+
+func (j *Journal) load() error {
+	f, err := os.Open(j.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var e JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// A torn write from a crash; the student is simply retried.
+			common.Warnf("journal %s line %d is unreadable, ignoring it: %v", j.Path, line, err)
+			continue
+		}
+		j.apply(e)
+	}
+	return scanner.Err()
+}
+
+func (j *Journal) apply(e JournalEntry) {
+	switch e.Outcome {
+	case JournalDone:
+		j.done[e.SchoolId] = true
+		delete(j.unverified, e.SchoolId)
+	case JournalUnverified:
+		j.unverified[e.SchoolId] = e
+		fallthrough
+	default:
+		j.failures[e.SchoolId]++
+		j.lastErr[e.SchoolId] = e.Error
+	}
+}
+
+// Done reports whether schoolId was fixed by an earlier run.
+func (j *Journal) Done(schoolId string) bool {
+	return j.done[schoolId]
+}
+
+// Unverified reports whether schoolId's photo was uploaded by an earlier
+// run but not verified, and returns the latest entry recording it. Its
+// fingerprint fields are empty for journals written before they were
+// recorded.
+func (j *Journal) Unverified(schoolId string) (JournalEntry, bool) {
+	e, ok := j.unverified[schoolId]
+	return e, ok
+}
+
+// Failures returns how many times schoolId has failed, and the last error.
+func (j *Journal) Failures(schoolId string) (int, string) {
+	return j.failures[schoolId], j.lastErr[schoolId]
+}
+
+// Record stamps e with the current time, appends it and syncs it to disk.
+func (j *Journal) Record(e JournalEntry) error {
+	if j.f == nil {
+		return fmt.Errorf("journal %s is read-only", j.Path)
+	}
+	e.At = time.Now()
+	j.apply(e)
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := j.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return j.f.Sync()
+}
+
+// Close closes the journal file.
+func (j *Journal) Close() error {
+	if j.f == nil {
+		return nil
+	}
+	return j.f.Close()
+}
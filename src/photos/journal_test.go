@@ -0,0 +1,45 @@
+package photos
+
+import (
+	"path/filepath"
+	"testing"
+
+	"isams_to_sheets/src/common"
+)
+
+func TestJournalUnverified(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "list.journal")
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []JournalEntry{
+		{SchoolId: "a", Outcome: JournalUnverified, Error: "mismatch", SHA256: "aaa", Width: 600, Height: 800, DHash: 1<<63 | 5, OriginalSHA256: "orig"},
+		{SchoolId: "b", Outcome: JournalUnverified, Error: "mismatch", SHA256: "bbb"},
+		{SchoolId: "b", Outcome: JournalDone},
+		{SchoolId: "c", Outcome: JournalFailed, Error: "upload failed"},
+	} {
+		if err := j.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	j.Close()
+
+	j, err = ReadJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, ok := j.Unverified("a")
+	if want := (common.PhotoFingerprint{SHA256: "aaa", Width: 600, Height: 800, DHash: 1<<63 | 5}); !ok || e.upload() != want || e.OriginalSHA256 != "orig" {
+		t.Errorf("Unverified(a) = %+v, %v, want the recorded upload", e, ok)
+	}
+	if _, ok := j.Unverified("b"); ok || !j.Done("b") {
+		t.Error("b was verified later and should be done")
+	}
+	if _, ok := j.Unverified("c"); ok {
+		t.Error("a failed upload is not unverified")
+	}
+	if n, lastErr := j.Failures("a"); n != 1 || lastErr != "mismatch" {
+		t.Errorf("Failures(a) = %d, %q, want 1, mismatch", n, lastErr)
+	}
+}
@@ -0,0 +1,188 @@
+package photos
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"isams_to_sheets/src/common"
+)
+
+// RollbackOptions configures Rollback. Either RunID or SchoolIds must be set.
+type RollbackOptions struct {
+	Backups *BackupStore
+	// RunID restores every photo that run replaced.
+	RunID string
+	// SchoolIds restores these students; with RunID, from that run,
+	// otherwise from their most recent backup.
+	SchoolIds []string
+	DryRun    bool
+}
+
+// ListBackupRuns writes every backup run with its number of replaced photos.
+func ListBackupRuns(w io.Writer, backups *BackupStore) error {
+	runs, err := backups.Runs()
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Fprintf(w, "No backup runs in %s.\n", backups.Dir)
+		return nil
+	}
+	for _, id := range runs {
+		entries, err := backups.RunEntries(id)
+		if err != nil {
+			fmt.Fprintf(w, "%s  (unreadable: %v)\n", id, err)
+			continue
+		}
+		uploaded := 0
+		for _, e := range entries {
+			if e.State == BackupUploaded {
+				uploaded++
+			}
+		}
+		fmt.Fprintf(w, "%s  %d photos saved, %d replaced\n", id, len(entries), uploaded)
+	}
+	return nil
+}
+
+type rollbackTarget struct {
+	entry BackupEntry
+	run   string
+}
+
+// Rollback re-uploads saved originals as the students' current photos. The
+// photos being replaced are themselves backed up in a "rollback" run first.
+// Entries whose replacement upload failed are skipped, since the original
+// was never replaced. Students who had no photo before the run are listed
+// and skipped too: iSAMS has no API to clear a photo.
+func Rollback(isams *common.ISAMSClient, opts RollbackOptions) error {
+	targets, err := rollbackTargets(opts)
+	if err != nil {
+		return err
+	}
+	var empty []string
+	restorable := targets[:0]
+	for _, t := range targets {
+		if t.entry.Empty {
+			empty = append(empty, t.entry.SchoolId)
+			continue
+		}
+		restorable = append(restorable, t)
+	}
+	targets = restorable
+	if len(empty) > 0 {
+		common.Warnf("%d students had no photo before the run; remove their photos in iSAMS by hand: %s", len(empty), strings.Join(empty, ", "))
+	}
+	if len(targets) == 0 {
+		log.Printf("Nothing to roll back.")
+		return nil
+	}
+
+	if opts.DryRun {
+		for _, t := range targets {
+			log.Printf("%s: would restore %d-byte %s original from run %s (dry run)", t.entry.SchoolId, t.entry.Size, t.entry.ContentType, t.run)
+		}
+		return nil
+	}
+
+	run, err := opts.Backups.StartRun("rollback")
+	if err != nil {
+		return fmt.Errorf("unable to start backup run: %w", err)
+	}
+	defer run.Close()
+
+	restored, failed := 0, 0
+	for _, t := range targets {
+		schoolId := t.entry.SchoolId
+		original, err := opts.Backups.Original(t.entry)
+		if err != nil {
+			log.Printf("%s: %v", schoolId, err)
+			failed++
+			continue
+		}
+		current, ct, err := isams.DownloadStudentPhotoBytes(schoolId)
+		switch {
+		case errors.Is(err, common.ErrNoStudentPhoto):
+			err = run.SaveEmpty(schoolId)
+		case err == nil:
+			err = run.Save(schoolId, current, ct)
+		}
+		if err != nil {
+			log.Printf("%s: could not back up the current photo, not restoring: %v", schoolId, err)
+			failed++
+			continue
+		}
+		err = isams.UploadStudentPhotoBytes(schoolId, original, t.entry.ContentType)
+		var unverified *common.UnverifiedPhotoError
+		if errors.As(err, &unverified) {
+			log.Printf("%s: restored but not verified: %s", schoolId, unverified.Reason)
+			if err := run.Uploaded(schoolId); err != nil {
+				common.Warnf("%s: could not record restore: %v", schoolId, err)
+			}
+			failed++
+			continue
+		}
+		if err != nil {
+			log.Printf("%s: restore failed: %v", schoolId, err)
+			if err := run.Failed(schoolId, err); err != nil {
+				common.Warnf("%s: could not record failed restore: %v", schoolId, err)
+			}
+			failed++
+			continue
+		}
+		if err := run.Uploaded(schoolId); err != nil {
+			common.Warnf("%s: could not record restore: %v", schoolId, err)
+		}
+		log.Printf("%s: restored original from run %s", schoolId, t.run)
+		restored++
+	}
+	log.Printf("Restored and verified %d photos, %d failed or unverified; the replaced photos are in run %s.", restored, failed, run.ID)
+	if failed > 0 {
+		return fmt.Errorf("%d photos could not be restored", failed)
+	}
+	return nil
+}
+
+func rollbackTargets(opts RollbackOptions) ([]rollbackTarget, error) {
+	if opts.RunID == "" && len(opts.SchoolIds) == 0 {
+		return nil, fmt.Errorf("give a run or a list of school IDs to roll back")
+	}
+	var targets []rollbackTarget
+	if opts.RunID == "" {
+		for _, id := range opts.SchoolIds {
+			entry, run, err := opts.Backups.LatestEntry(id)
+			if err != nil {
+				return nil, err
+			}
+			targets = append(targets, rollbackTarget{entry: entry, run: run})
+		}
+		return targets, nil
+	}
+
+	entries, err := opts.Backups.RunEntries(opts.RunID)
+	if err != nil {
+		return nil, err
+	}
+	wanted := map[string]bool{}
+	for _, id := range opts.SchoolIds {
+		wanted[id] = true
+	}
+	for _, e := range entries {
+		if len(wanted) > 0 && !wanted[e.SchoolId] {
+			continue
+		}
+		delete(wanted, e.SchoolId)
+		if e.State == BackupFailed {
+			common.Debugf("%s: upload failed in run %s, nothing to restore", e.SchoolId, opts.RunID)
+			continue
+		}
+		targets = append(targets, rollbackTarget{entry: e, run: opts.RunID})
+	}
+	for id := range wanted {
+		return nil, fmt.Errorf("run %s has no backup for %s", opts.RunID, id)
+	}
+	return targets, nil
+}
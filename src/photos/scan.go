@@ -0,0 +1,60 @@
+// Package photos holds the student photo maintenance tasks: finding
+// oversized originals in iSAMS and replacing them with compressed copies.
+package photos
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"isams_to_sheets/src/common"
+)
+
+// ScanOptions configures Scan.
+type ScanOptions struct {
+	// ThresholdBytes is the original size at or above which a photo is listed.
+	ThresholdBytes int
+	OutPath        string
+}
+
+// Scan downloads every student photo and writes those whose original is at
+// least ThresholdBytes to OutPath as CSV.
+func Scan(isams *common.ISAMSClient, opts ScanOptions) error {
+	students, err := isams.FetchAllStudents()
+	if err != nil {
+		return fmt.Errorf("unable to fetch students: %w", err)
+	}
+
+	f, err := os.Create(opts.OutPath)
+	if err != nil {
+		return fmt.Errorf("unable to create output file: %w", err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	defer w.Flush()
+
+	// Write header
+	_, _ = w.WriteString("SchoolId,FullName,OriginalSizeKB,CompressedSizeKB,Status\n")
+
+	start := time.Now()
+	count := 0
+	for _, s := range students {
+		photo, err := isams.FetchStudentPhoto(s.SchoolId)
+		if err != nil || photo == nil {
+			continue
+		}
+		if photo.OriginalSize >= opts.ThresholdBytes {
+			origKB := strconv.FormatFloat(float64(photo.OriginalSize)/1024.0, 'f', 1, 64)
+			compKB := strconv.FormatFloat(float64(photo.CompressedSize)/1024.0, 'f', 1, 64)
+			line := fmt.Sprintf("%s,%s,%s,%s,%s\n", s.SchoolId, s.FullName, origKB, compKB, photo.Status)
+			_, _ = w.WriteString(line)
+			count++
+		}
+	}
+
+	log.Printf("Wrote %d records with photos >=%dKB to %s in %s", count, opts.ThresholdBytes/1024, opts.OutPath, time.Since(start))
+	return nil
+}
@@ -1,10 +1,12 @@
-package main
+// Package roster compares iSAMS students with rosters kept in spreadsheets,
+// such as the transport lists.
+package roster
 
 import (
 	"bufio"
 	"encoding/csv"
-	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -12,10 +14,21 @@ import (
 
 	"isams_to_sheets/src/common"
 
-	"github.com/joho/godotenv"
 	"github.com/xuri/excelize/v2"
 )
 
+// MatchOptions configures Match.
+type MatchOptions struct {
+	File   string
+	Sheet  string
+	Column string
+	// OutPath is an optional CSV (or tab-separated .txt) output file; stdout
+	// is used when empty.
+	OutPath string
+	// Missing selects the students NOT present in the roster column.
+	Missing bool
+}
+
 // columnLetterToIndex converts an Excel column letter (e.g. "A", "J") to 1-based index.
 func columnLetterToIndex(col string) int {
 	col = strings.ToUpper(strings.TrimSpace(col))
@@ -70,47 +83,26 @@ func readColumnValues(xlsxPath, sheetName, columnLetter string) (map[string]stru
 	return values, nil
 }
 
-func main() {
-	_ = godotenv.Load()
-
-	defaultXlsx := "/Users/aliifz/projects/alice-smith/klass-scripts/trans/27August.xlsx"
-	xlsxPath := flag.String("file", defaultXlsx, "Path to the .xlsx file in trans folder")
-	sheetName := flag.String("sheet", "", "Sheet name (default: first sheet)")
-	column := flag.String("col", "J", "Column letter to read IDs from")
-	outPath := flag.String("out", "", "Optional path to write CSV output (default: stdout)")
-	missing := flag.Bool("missing", false, "If true, output students NOT present in the Excel column")
-	flag.Parse()
-
-	apiKeyUrl := os.Getenv("API_KEY_URL")
-	if apiKeyUrl == "" {
-		log.Fatal("API_KEY_URL environment variable is not set")
-	}
-
-	idsFromXlsx, err := readColumnValues(*xlsxPath, *sheetName, *column)
+// Match lists the JB students (year groups below 7) whose school ID does, or
+// with Missing does not, appear in the roster column.
+func Match(isams *common.ISAMSClient, stdout io.Writer, opts MatchOptions) error {
+	idsFromXlsx, err := readColumnValues(opts.File, opts.Sheet, opts.Column)
 	if err != nil {
-		log.Fatalf("failed reading xlsx: %v", err)
+		return fmt.Errorf("failed reading xlsx: %w", err)
 	}
 	if len(idsFromXlsx) == 0 {
-		log.Fatalf("no IDs found in column %s of %s", *column, *xlsxPath)
+		return fmt.Errorf("no IDs found in column %s of %s", opts.Column, opts.File)
 	}
 
-	bearerToken, err := common.GetBearerToken(apiKeyUrl)
+	students, err := isams.FetchAllStudents()
 	if err != nil {
-		log.Fatalf("failed to get bearer token: %v", err)
-	}
-	bearer := "Bearer " + bearerToken
-
-	students, err := common.FetchAllStudents(bearer)
-	if err != nil {
-		log.Fatalf("failed to fetch students: %v", err)
+		return fmt.Errorf("failed to fetch students: %w", err)
 	}
 
 	// Exclude year groups 7-13 (EP)
 	filtered := make([]common.Student, 0, len(students))
 	for _, s := range students {
-		yg := strings.TrimSpace(fmt.Sprintf("%v", s.YearGroup))
-		switch yg {
-		case "7", "8", "9", "10", "11", "12", "13":
+		if common.IsEPYearGroup(s.YearGroup) {
 			continue
 		}
 		filtered = append(filtered, s)
@@ -119,7 +111,7 @@ func main() {
 
 	// Select data set: matched or missing
 	selected := make([]common.Student, 0)
-	if *missing {
+	if opts.Missing {
 		for _, s := range students {
 			if _, ok := idsFromXlsx[strings.TrimSpace(s.SchoolId)]; !ok {
 				selected = append(selected, s)
@@ -138,19 +130,19 @@ func main() {
 		w  *csv.Writer
 		cf *os.File
 	)
-	if *outPath != "" {
+	if opts.OutPath != "" {
 		// Ensure directory exists
-		dir := filepath.Dir(*outPath)
+		dir := filepath.Dir(opts.OutPath)
 		if dir != "." && dir != "" {
 			_ = os.MkdirAll(dir, 0755)
 		}
-		cf, err = os.Create(*outPath)
+		cf, err = os.Create(opts.OutPath)
 		if err != nil {
-			log.Fatalf("create output file: %v", err)
+			return fmt.Errorf("create output file: %w", err)
 		}
 		defer cf.Close()
 		// If output extension is .txt, write tab-separated text
-		if strings.HasSuffix(strings.ToLower(*outPath), ".txt") {
+		if strings.HasSuffix(strings.ToLower(opts.OutPath), ".txt") {
 			bw := bufio.NewWriter(cf)
 			defer bw.Flush()
 			_, _ = bw.WriteString("schoolId\tfullName\tformGroup\tyearGroup\temail\n")
@@ -165,15 +157,15 @@ func main() {
 				_, _ = bw.WriteString(line)
 			}
 			mode := "matched"
-			if *missing {
+			if opts.Missing {
 				mode = "missing"
 			}
-			log.Printf("transmatch: wrote %d %s students to %s", len(selected), mode, *outPath)
-			return
+			log.Printf("roster match: wrote %d %s students to %s", len(selected), mode, opts.OutPath)
+			return nil
 		}
 		w = csv.NewWriter(cf)
 	} else {
-		w = csv.NewWriter(os.Stdout)
+		w = csv.NewWriter(stdout)
 	}
 	defer w.Flush()
 
@@ -191,8 +183,10 @@ func main() {
 
 	// Log a summary to stderr (won't pollute CSV on stdout)
 	mode := "matched"
-	if *missing {
+	if opts.Missing {
 		mode = "missing"
 	}
-	log.Printf("transmatch: wrote %d %s students", len(selected), mode)
+	log.Printf("roster match: wrote %d %s students", len(selected), mode)
+	return nil
+
 }
@@ -0,0 +1,154 @@
+// Package tams reads and writes the TAMS access control workbooks.
+package tams
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"isams_to_sheets/src/common"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ExportOptions configures Export.
+type ExportOptions struct {
+	File  string
+	Sheet string
+	// DryRun fills the sheet in memory but does not save the workbook.
+	DryRun bool
+}
+
+// Export replaces the data rows of the TAMS students sheet with the current
+// iSAMS student list, creating the workbook or sheet if needed.
+func Export(isams *common.ISAMSClient, opts ExportOptions) error {
+	students, err := isams.FetchAllStudents()
+	if err != nil {
+		return fmt.Errorf("failed to fetch students: %w", err)
+	}
+
+	// Open or create workbook
+	f, err := excelize.OpenFile(opts.File)
+	createdNew := false
+	if err != nil {
+		if os.IsNotExist(err) {
+			f = excelize.NewFile()
+			createdNew = true
+		} else {
+			return fmt.Errorf("failed to open xlsm file: %w", err)
+		}
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Printf("warning: failed to close xlsm file: %v", err)
+		}
+	}()
+
+	// Ensure sheet exists; create if missing
+	idx, idxErr := f.GetSheetIndex(opts.Sheet)
+	if idxErr != nil {
+		return fmt.Errorf("failed to get sheet index for %q: %w", opts.Sheet, idxErr)
+	}
+	if idx == -1 {
+		newIdx, err := f.NewSheet(opts.Sheet)
+		if err != nil {
+			return fmt.Errorf("failed to create sheet %q: %w", opts.Sheet, err)
+		}
+		f.SetActiveSheet(newIdx)
+		if createdNew {
+			if defIdx, _ := f.GetSheetIndex("Sheet1"); defIdx != -1 && opts.Sheet != "Sheet1" {
+				_ = f.DeleteSheet("Sheet1")
+			}
+		}
+	}
+
+	// Clear existing data rows (keep header)
+	rows, err := f.GetRows(opts.Sheet)
+	if err != nil {
+		return fmt.Errorf("failed to read rows: %w", err)
+	}
+	// Remove from bottom to row 2
+	for r := len(rows); r >= 2; r-- {
+		if err := f.RemoveRow(opts.Sheet, r); err != nil {
+			return fmt.Errorf("failed to remove row %d: %w", r, err)
+		}
+	}
+
+	// Header as per spec
+	headers := []interface{}{
+		"Member Id",
+		"Name",
+		"Logon Id",
+		"Member Type",
+		"Category",
+		"Teacher Grade",
+		"Email",
+		"Smart Card No",
+		"Department",
+		"Job Description",
+		"Is Host",
+		"Access Start Date",
+		"Access End Date",
+		"Join Date",
+		"Location",
+		"Class",
+	}
+	if err := f.SetSheetRow(opts.Sheet, "A1", &headers); err != nil {
+		return fmt.Errorf("failed to write headers: %w", err)
+	}
+
+	// Date formatting: dd/MM/YYYY
+	now := time.Now()
+	startDate := now.Format("02/01/2006")
+	endDate := now.AddDate(10, 0, 0).Format("02/01/2006")
+
+	rowIdx := 2
+	for _, s := range students {
+		location := "Jalan Bellamy"
+		if common.IsEPYearGroup(s.YearGroup) {
+			location = "Equine Park"
+		}
+		row := []interface{}{
+			s.SchoolId,  // Member Id
+			s.FullName,  // Name
+			s.SchoolId,  // Logon Id
+			"Student",   // Member Type
+			"STUDENT",   // Category
+			"",          // Teacher Grade
+			s.Email,     // Email
+			"",          // Smart Card No
+			"",          // Department
+			"",          // Job Description
+			"",          // Is Host
+			startDate,   // Access Start Date
+			endDate,     // Access End Date
+			startDate,   // Join Date
+			location,    // Location
+			s.FormGroup, // Class
+		}
+		cell := fmt.Sprintf("A%d", rowIdx)
+		if err := f.SetSheetRow(opts.Sheet, cell, &row); err != nil {
+			return fmt.Errorf("failed to write row %d: %w", rowIdx, err)
+		}
+		rowIdx++
+	}
+
+	if opts.DryRun {
+		log.Printf("Dry run: %q sheet would be updated with %d students; workbook not saved.", opts.Sheet, len(students))
+		return nil
+	}
+
+	if createdNew {
+		if err := f.SaveAs(opts.File); err != nil {
+			return fmt.Errorf("failed to save new workbook: %w", err)
+		}
+	} else {
+		if err := f.Save(); err != nil {
+			return fmt.Errorf("failed to save workbook: %w", err)
+		}
+	}
+
+	log.Printf("Updated %q sheet with %d students.", opts.Sheet, len(students))
+	return nil
+}
@@ -0,0 +1,80 @@
+package tams
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// InspectOptions configures Inspect.
+type InspectOptions struct {
+	File string
+	// MaxRows and MaxCols limit the preview printed for each sheet.
+	MaxRows int
+	MaxCols int
+}
+
+// Inspect prints the sheets of a workbook with a preview of their first rows.
+func Inspect(w io.Writer, opts InspectOptions) error {
+	if _, err := os.Stat(opts.File); err != nil {
+		return fmt.Errorf("cannot access file %q: %w", opts.File, err)
+	}
+
+	f, err := excelize.OpenFile(opts.File)
+	if err != nil {
+		return fmt.Errorf("failed to open Excel file: %w", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Printf("warning: failed to close file: %v", err)
+		}
+	}()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		log.Println("no sheets found")
+		return nil
+	}
+
+	fmt.Fprintf(w, "Opened %q\n", opts.File)
+	fmt.Fprintf(w, "Found %d sheets:\n", len(sheets))
+	for i, name := range sheets {
+		fmt.Fprintf(w, "  %d. %s\n", i+1, name)
+	}
+
+	fmt.Fprintln(w)
+	for _, sheetName := range sheets {
+		rows, err := f.GetRows(sheetName)
+		if err != nil {
+			log.Printf("failed to read rows for sheet %q: %v", sheetName, err)
+			continue
+		}
+
+		fmt.Fprintf(w, "Sheet: %s (total rows: %d)\n", sheetName, len(rows))
+		limit := opts.MaxRows
+		if limit > len(rows) {
+			limit = len(rows)
+		}
+		for r := 0; r < limit; r++ {
+			row := rows[r]
+			cLimit := opts.MaxCols
+			if cLimit > len(row) {
+				cLimit = len(row)
+			}
+			fmt.Fprintf(w, "  %4d |", r+1)
+			for c := 0; c < cLimit; c++ {
+				val := row[c]
+				fmt.Fprintf(w, " %s", val)
+				if c < cLimit-1 {
+					fmt.Fprint(w, " |")
+				}
+			}
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package usersync
+
+import (
+	"fmt"
+	"io"
+
+	"isams_to_sheets/src/common"
+)
+
+// CardsOptions configures CheckCards.
+type CardsOptions struct {
+	CardSources []common.CardSource
+	Cards       common.CardsConfig
+	// StaffDataset and StaffView select the active employees.
+	StaffDataset string
+	StaffView    string
+	// Output is the report format: text or json.
+	Output string
+}
+
+// loadCards reads the card registry. A sync still runs without one, sending
+// no card numbers, so a missing source is only a warning.
+func loadCards(sources []common.CardSource, cfg common.CardsConfig) *common.CardRegistry {
+	cards, err := common.LoadCards(sources, cfg)
+	if err != nil {
+		common.Warnf("could not load card numbers: %v", err)
+		return common.NewCardRegistry(nil, nil)
+	}
+	return cards
+}
+
+// checkCards logs the card problems among the holders a sync is about to
+// send. Holders without a card are only counted, except at debug level.
+func checkCards(cards *common.CardRegistry, holders []common.CardHolder) {
+	check := cards.Check(holders)
+	if check.Empty() {
+		return
+	}
+	common.Warnf("cards: %s (run klass cards check for every population)", check.Summary())
+	for _, d := range check.Duplicates {
+		common.Warnf("card %s is held by more than one person:", d.Number)
+		for _, h := range d.Holders {
+			common.Warnf("  %s %s %s (%s)", h.Population, h.ID, h.Name, h.Where())
+		}
+	}
+	for _, row := range check.Invalid {
+		common.Warnf("invalid card %q for %s %s (%s): %s", row.Raw, row.ID, row.Name, row.Where(), row.Problem)
+	}
+	for _, h := range check.Missing {
+		common.Debugf("no card for %s %s %s", h.Population, h.ID, h.Name)
+	}
+}
+
+func studentHolders(cards *common.CardRegistry, students []common.Student) []common.CardHolder {
+	holders := make([]common.CardHolder, len(students))
+	for i, s := range students {
+		holders[i] = cards.Holder(common.CardStudents, s.SchoolId, s.FullName)
+	}
+	return holders
+}
+
+func staffHolders(cards *common.CardRegistry, staff []StaffRecord) []common.CardHolder {
+	holders := make([]common.CardHolder, len(staff))
+	for i, s := range staff {
+		holders[i] = cards.Holder(common.CardStaff, staffId(s), s.EmployeeName)
+	}
+	return holders
+}
+
+func familyHolders(cards *common.CardRegistry) []common.CardHolder {
+	rows, ids := familyRows(cards.Rows)
+	holders := make([]common.CardHolder, len(rows))
+	for i, row := range rows {
+		holders[i] = common.FamilyHolder(ids[i], row)
+	}
+	return holders
+}
+
+// CheckCards checks the card numbers of every student, active staff member
+// and family card holder together, so one card assigned to people in
+// different populations shows up, and writes the problems to w.
+func CheckCards(isams *common.ISAMSClient, kf *common.KissflowClient, w io.Writer, opts CardsOptions) error {
+	cards, err := common.LoadCards(opts.CardSources, opts.Cards)
+	if err != nil {
+		return err
+	}
+	students, err := isams.FetchAllStudents()
+	if err != nil {
+		return fmt.Errorf("unable to fetch students: %w", err)
+	}
+	staff, err := fetchAllStaff(kf, opts.StaffDataset, opts.StaffView)
+	if err != nil {
+		return fmt.Errorf("unable to fetch staff: %w", err)
+	}
+
+	var holders []common.CardHolder
+	holders = append(holders, studentHolders(cards, students)...)
+	holders = append(holders, staffHolders(cards, staff)...)
+	holders = append(holders, familyHolders(cards)...)
+	return cards.Check(holders).Write(w, opts.Output)
+}
@@ -0,0 +1,219 @@
+package usersync
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"isams_to_sheets/src/common"
+)
+
+// FamiliesOptions configures the family card holder sync.
+type FamiliesOptions struct {
+	Options
+	// CardSources list the family and driver cards, in precedence order.
+	CardSources []common.CardSource
+	// OutPath receives a CSV of every family row that was processed.
+	OutPath string
+	// StudentsDataset and StudentsView select the Kissflow students used to
+	// tell active families from former ones.
+	StudentsDataset string
+	StudentsView    string
+	// KissflowExport is an export of the same students, read when
+	// StudentsDataset is unset or cannot be listed.
+	KissflowExport string
+}
+
+// processID handles the ID processing according to the rules:
+// 1. Get first 5 digits
+// 2. Only digits
+// 3. If letters appear, exclude digits after letters
+// 4. If string starts with letter, get only digits
+func processID(id string) string {
+	var result strings.Builder
+
+	// Check if starts with letter
+	if len(id) > 0 && unicode.IsLetter(rune(id[0])) {
+		// If starts with letter, just extract digits
+		for _, char := range id {
+			if unicode.IsDigit(char) {
+				result.WriteRune(char)
+				if result.Len() == 5 {
+					break
+				}
+			}
+		}
+	} else {
+		// Normal case: take digits until letter appears
+		for _, char := range id {
+			if unicode.IsLetter(char) {
+				break
+			}
+			if unicode.IsDigit(char) {
+				result.WriteRune(char)
+				if result.Len() == 5 {
+					break
+				}
+			}
+		}
+	}
+
+	return result.String()
+}
+
+// isFamilyRecord reports whether a User_Master Parents record is a family
+// card holder created by the families sync.
+func isFamilyRecord(rec map[string]interface{}) bool {
+	return common.FieldString(rec["AccessGroup"]) == "FAMILY"
+}
+
+// familyRows picks the family and driver rows out of every card row and
+// numbers them by their processed ID, so the second card of family 12345
+// becomes 12345_2. The count runs over all rows, not just family ones, as
+// the P1 processor always numbered them: a staff card whose ID processes to
+// 12345 ahead of the family's first card makes that card 12345_2.
+func familyRows(all []common.CardRow) ([]common.CardRow, []string) {
+	idCount := make(map[string]int)
+	var rows []common.CardRow
+	var ids []string
+	for _, row := range all {
+		processedID := processID(row.ID)
+		idCount[processedID]++
+		if row.IsFamily() {
+			rows = append(rows, row)
+			ids = append(ids, fmt.Sprintf("%s_%d", processedID, idCount[processedID]))
+		}
+	}
+	return rows, ids
+}
+
+// Families syncs family and driver cards from the P1 export into the
+// User_Master Parents view, marking families without any current student as
+// inactive.
+func Families(kf *common.KissflowClient, opts FamiliesOptions) error {
+	cards, err := common.LoadCards(opts.CardSources, opts.Cards)
+	if err != nil {
+		return fmt.Errorf("error reading input file: %w", err)
+	}
+	rows, ids := familyRows(cards.Rows)
+	checkCards(cards, familyHolders(cards))
+
+	directory, err := loadFamilyDirectory(kf, opts)
+	if err != nil {
+		return err
+	}
+	producedAt := directory.ProducedAt.Format(time.RFC3339)
+	log.Printf("Family status from %s, produced %s (%d families)", directory.Source, producedAt, directory.Len())
+
+	// Create output CSV file
+	outputFile, err := os.Create(opts.OutPath)
+	if err != nil {
+		return fmt.Errorf("error creating output file: %w", err)
+	}
+	defer outputFile.Close()
+
+	// Create CSV writer
+	writer := csv.NewWriter(outputFile)
+	defer writer.Flush()
+
+	// Write header
+	if err := writer.Write([]string{"ID", "Processed ID", "Name", "Department", "Column J", "Parent Membership No", "Active Status", "Status Source", "Status Produced At"}); err != nil {
+		return fmt.Errorf("error writing header: %w", err)
+	}
+
+	// Slice to accumulate payloads for User_Master batch
+	var payloads []map[string]interface{}
+	for i, row := range rows {
+		processedID := processID(row.ID)
+		processedIDWithCount := ids[i]
+
+		// A family is active while any of its children is not former; one
+		// missing from the export is inactive.
+		var parentMembershipNo string
+		isActive := false
+		if family, ok := directory.Lookup(processedID); ok {
+			parentMembershipNo = family.MembershipNo
+			isActive = family.Active()
+		}
+		activeStatus := "Inactive"
+		if isActive {
+			activeStatus = "Active"
+		}
+
+		// Determine Kissflow Status value (1 = Active, 2 = Inactive)
+		statusVal := "2"
+		if isActive {
+			statusVal = "1"
+		}
+
+		// Build payload for User_Master batch
+		payloads = append(payloads, map[string]interface{}{
+			"_id":         processedIDWithCount,
+			"Name":        processedIDWithCount,
+			"Name_1":      strings.ToUpper(row.Name),
+			"Department":  row.Department,
+			"CardNo":      row.Number,
+			"Type":        "2",
+			"Status":      statusVal,
+			"IdentityNo":  parentMembershipNo, // no special characters allowed
+			"AccessGroup": "FAMILY",
+		})
+
+		if err := writer.Write([]string{row.ID, processedIDWithCount, row.Name, row.Department, row.Raw, parentMembershipNo, activeStatus, directory.Source, producedAt}); err != nil {
+			return fmt.Errorf("error writing record: %w", err)
+		}
+	}
+
+	// After processing CSV, reconcile the family records in the Parents view
+	// with the accumulated payloads
+	if len(payloads) == 0 {
+		fmt.Println("No payloads generated to send to User_Master.")
+		return nil
+	}
+	current, err := kf.FetchUserMasterRecords("Parents")
+	if err != nil {
+		return err
+	}
+	changes := common.Reconcile("Parents", current, payloads, common.ReconcileOptions{Owns: isFamilyRecord, Deactivate: deactivation("family card no longer in the card sources")})
+	if _, err := commit(kf, "families", current, changes, opts.Options); err != nil {
+		return err
+	}
+
+	log.Printf("Processing complete. Processed %d rows. Results saved to %s", len(rows), opts.OutPath)
+	return nil
+}
+
+// staleExportAge is how old the Kissflow export can be before the families
+// sync warns that family status may be out of date.
+const staleExportAge = 7 * 24 * time.Hour
+
+// loadFamilyDirectory lists the students from Kissflow, falling back to the
+// export when the dataset is not configured or cannot be read. Either way
+// the fallback is warned about, since the export is only as current as its
+// last download.
+func loadFamilyDirectory(kf *common.KissflowClient, opts FamiliesOptions) (*common.FamilyDirectory, error) {
+	if opts.StudentsDataset == "" {
+		common.Warnf("kissflow.students_dataset is not set, so family status comes from the export %s and may be stale; set it (or KLASS_KISSFLOW_STUDENTS_DATASET) to read students live", opts.KissflowExport)
+	} else {
+		directory, err := common.FetchFamilyDirectory(kf, opts.StudentsDataset, opts.StudentsView)
+		if err == nil {
+			return directory, nil
+		}
+		if opts.KissflowExport == "" {
+			return nil, err
+		}
+		common.Warnf("could not list students from Kissflow, using the export %s instead: %v", opts.KissflowExport, err)
+	}
+	directory, err := common.LoadFamilyDirectory(opts.KissflowExport)
+	if err != nil {
+		return nil, err
+	}
+	if age := time.Since(directory.ProducedAt); age > staleExportAge {
+		common.Warnf("%s is %d days old; families whose children joined or left since then get the wrong status", directory.Source, int(age.Hours()/24))
+	}
+	return directory, nil
+}
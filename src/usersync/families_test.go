@@ -0,0 +1,50 @@
+package usersync
+
+import (
+	"reflect"
+	"testing"
+
+	"isams_to_sheets/src/common"
+)
+
+func TestProcessID(t *testing.T) {
+	tests := map[string]string{
+		"12345":    "12345",
+		"1234567":  "12345",
+		"123A45":   "123",
+		"A12B345":  "12345",
+		"P-00123":  "00123",
+		"":         "",
+		"FAMILY":   "",
+		"12 34-56": "12345",
+	}
+	for in, want := range tests {
+		if got := processID(in); got != want {
+			t.Errorf("processID(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// TestFamilyRowsNumbering pins the numbering of the P1 processor: suffixes
+// count every row with the same processed ID, family or not.
+func TestFamilyRowsNumbering(t *testing.T) {
+	all := []common.CardRow{
+		{Line: 1, ID: "12345", Department: "Staff"},
+		{Line: 2, ID: "12345A", Department: "Family"},
+		{Line: 3, ID: "67890", Department: "FAMILY EXPERIENCE"},
+		{Line: 4, ID: "A12345", Department: "Driver"},
+		{Line: 5, ID: "67890", Department: "Family"},
+		{Line: 6, ID: "55555", Department: "Family"},
+	}
+	rows, ids := familyRows(all)
+	var lines []int
+	for _, row := range rows {
+		lines = append(lines, row.Line)
+	}
+	if want := []int{2, 4, 5, 6}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("family rows = %v, want lines %v", lines, want)
+	}
+	if want := []string{"12345_2", "12345_3", "67890_2", "55555_1"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+}
@@ -0,0 +1,88 @@
+package usersync
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+
+	"isams_to_sheets/src/common"
+)
+
+// OthersOptions configures the "Others" card holder sync.
+type OthersOptions struct {
+	Options
+	// CSVPath is the P1_OTHERS export. Its header row names the User_Master
+	// fields and each row becomes one payload keyed by Name.
+	CSVPath string
+}
+
+// Others syncs contractors and other card holders from the P1_OTHERS export
+// into the User_Master Others view. A CardNo column is validated and
+// converted like the P1 card export.
+func Others(kf *common.KissflowClient, opts OthersOptions) error {
+	// Open the input CSV file
+	inputFile, err := os.Open(opts.CSVPath)
+	if err != nil {
+		return fmt.Errorf("error opening input file: %w", err)
+	}
+	defer inputFile.Close()
+
+	reader := csv.NewReader(inputFile)
+	reader.FieldsPerRecord = -1
+
+	// Read header row
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("error reading CSV header: %w", err)
+	}
+
+	var payloads []map[string]interface{}
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if err != nil {
+			break
+		}
+		if len(row) == 0 {
+			continue
+		}
+
+		payload := make(map[string]interface{})
+		for i := range header {
+			if i < len(row) {
+				payload[header[i]] = row[i]
+			} else {
+				payload[header[i]] = ""
+			}
+		}
+		// Ensure _id is present; set it to the same value as Name
+		if nameVal, ok := payload["Name"]; ok {
+			payload["_id"] = nameVal
+		}
+		if raw, ok := payload["CardNo"].(string); ok {
+			card, err := common.NormalizeCardNumber(raw, opts.Cards)
+			if err != nil {
+				common.Warnf("invalid card %q for %v (line %d), sending no card: %v", raw, payload["Name"], line, err)
+			}
+			payload["CardNo"] = card
+		}
+		payloads = append(payloads, payload)
+	}
+
+	if len(payloads) == 0 {
+		fmt.Println("No payloads generated to send to User_Master for 'Others'.")
+		return nil
+	}
+
+	current, err := kf.FetchUserMasterRecords("Others")
+	if err != nil {
+		return err
+	}
+	changes := common.Reconcile("Others", current, payloads, common.ReconcileOptions{Deactivate: deactivation("no longer in the others export")})
+	applied, err := commit(kf, "others", current, changes, opts.Options)
+	if err != nil || !applied {
+		return err
+	}
+	log.Printf("Successfully reconciled %d 'Others' payloads with User_Master.", len(payloads))
+	return nil
+}
@@ -0,0 +1,108 @@
+package usersync
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"isams_to_sheets/src/common"
+)
+
+type ParentRecord struct {
+	Name     string `json:"Name"`
+	Forename string `json:"Contact_Forename"`
+	Surname  string `json:"Contact_Surname"`
+	Email    string `json:"Contact_EmailAddress"`
+}
+
+// ParentsOptions configures the parents sync.
+type ParentsOptions struct {
+	Options
+	// Dataset is the Kissflow dataset holding the iSAMS family contacts.
+	Dataset string
+}
+
+func fetchAllParents(kf *common.KissflowClient, dataset string) ([]ParentRecord, error) {
+	return common.ListDataset[ParentRecord](kf, dataset)
+}
+
+// isParentRecord reports whether a User_Master Parents record belongs to this
+// sync. Family card holders share the Parents view but are owned by
+// the families sync, which tags them with AccessGroup FAMILY.
+func isParentRecord(rec map[string]interface{}) bool {
+	return common.FieldString(rec["AccessGroup"]) != "FAMILY"
+}
+
+func stripSSOSuffix(name string) string {
+	return strings.TrimSuffix(name, "- SSO")
+}
+
+func mapParentToRow(s ParentRecord) []interface{} {
+	return []interface{}{
+		strings.TrimSuffix(s.Email, "@asis.edu.my"),                  // parentId
+		stripSSOSuffix(s.Forename) + " " + stripSSOSuffix(s.Surname), // Name
+		"",        // jobTitle
+		"Parents", // department
+		"",        // IdentityNo
+		"3",       // IdentityType
+		"2",       // Gender
+	}
+}
+
+func mapParentToUserMasterPayload(s ParentRecord) map[string]interface{} {
+	return map[string]interface{}{
+		"_id":          strings.TrimSuffix(s.Email, "@asis.edu.my"),
+		"Name":         strings.TrimSuffix(s.Email, "@asis.edu.my"),
+		"Name_1":       stripSSOSuffix(s.Forename) + " " + stripSSOSuffix(s.Surname),
+		"Type":         "2", // 2 = Parent, 3 = Student, 1 = Staff
+		"Job_Title":    "",
+		"Department":   "Parents",
+		"IdentityNo":   "",
+		"IdentityType": "",
+		"Gender":       "2",
+		"Status":       "1",
+	}
+}
+
+// Parents syncs the iSAMS family contacts into the User_Master Parents view
+// and the parents sheet.
+func Parents(kf *common.KissflowClient, opts ParentsOptions) error {
+	parents, err := fetchAllParents(kf, opts.Dataset)
+	if err != nil {
+		return fmt.Errorf("unable to fetch parents: %w", err)
+	}
+
+	// Prepare payloads for User_Master batch
+	var payloads []map[string]interface{}
+	for _, s := range parents {
+		payloads = append(payloads, mapParentToUserMasterPayload(s))
+	}
+
+	current, err := kf.FetchUserMasterRecords("Parents")
+	if err != nil {
+		return err
+	}
+	changes := common.Reconcile("Parents", current, payloads, common.ReconcileOptions{Owns: isParentRecord, Deactivate: deactivation("no longer an iSAMS family contact")})
+	applied, err := commit(kf, "parents", current, changes, opts.Options)
+	if err != nil || !applied {
+		return err
+	}
+
+	headers := []interface{}{"parentId", "Name", "jobTitle", "department", "IdentityNo", "IdentityType", "Gender"}
+	values := [][]interface{}{headers}
+	for _, s := range parents {
+		values = append(values, mapParentToRow(s))
+	}
+
+	srv, err := common.NewSheetsService(context.Background(), opts.Sheets.ServiceAccount)
+	if err != nil {
+		return err
+	}
+	if err := common.ReplaceSheetValues(srv, opts.Sheets.SpreadsheetID, opts.Sheets.ParentsTab, values, 0); err != nil {
+		return err
+	}
+
+	log.Printf("Done! Wrote %d parent records to the sheet.", len(parents))
+	return nil
+}
@@ -0,0 +1,66 @@
+package usersync
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"isams_to_sheets/src/common"
+)
+
+// population is the User_Master view a sync writes and the records in it
+// the sync owns.
+type population struct {
+	view string
+	owns func(rec map[string]interface{}) bool
+}
+
+var populations = map[string]population{
+	common.PopulationStudents: {view: "Students"},
+	common.PopulationStaff:    {view: "Staff"},
+	common.PopulationParents:  {view: "Parents", owns: isParentRecord},
+	common.PopulationFamilies: {view: "Parents", owns: isFamilyRecord},
+	common.PopulationOthers:   {view: "Others"},
+}
+
+// PurgeOptions configures Purge.
+type PurgeOptions struct {
+	Options
+	// Populations to purge; see common.Populations.
+	Populations []string
+	Retention   common.RetentionConfig
+}
+
+// Purge hard-deletes the records the syncs deactivated once they are older
+// than their population's retention period. Populations with a retention
+// of zero are skipped.
+func Purge(kf *common.KissflowClient, opts PurgeOptions) error {
+	if opts.PlanPath != "" && len(opts.Populations) != 1 {
+		return fmt.Errorf("-plan needs exactly one population, got %d", len(opts.Populations))
+	}
+	now := time.Now()
+	for _, name := range opts.Populations {
+		pop, ok := populations[name]
+		if !ok {
+			return fmt.Errorf("unknown population %q", name)
+		}
+		days, err := opts.Retention.RetentionDays(name)
+		if err != nil {
+			return err
+		}
+		if days <= 0 {
+			log.Printf("Keeping deactivated %s forever (retention.%s is 0)", name, name)
+			continue
+		}
+		current, err := kf.FetchUserMasterRecords(pop.view)
+		if err != nil {
+			return err
+		}
+		changes := common.PurgeDeactivated(pop.view, current, pop.owns, time.Duration(days)*24*time.Hour, now)
+		log.Printf("Purging %s deactivated more than %d days ago: %d records", name, days, len(changes.Deletes))
+		if _, err := commit(kf, "purge-"+name, current, changes, opts.Options); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package usersync
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"isams_to_sheets/src/common"
+)
+
+type StaffRecord struct {
+	Name         string `json:"Name"`
+	EmployeeName string `json:"Employee_Name"`
+	Designation  string `json:"Designation"`
+	Department   string `json:"Department"`
+	Email        string `json:"Email_Address"`
+	Gender       string `json:"Gender"`
+}
+
+// StaffOptions configures the staff sync.
+type StaffOptions struct {
+	Options
+	// CardSources supply the card numbers, in precedence order.
+	CardSources []common.CardSource
+	// Dataset and View select the active employees in Kissflow HR.
+	Dataset string
+	View    string
+}
+
+func fetchAllStaff(kf *common.KissflowClient, dataset, view string) ([]StaffRecord, error) {
+	return common.ListView[StaffRecord](kf, dataset, view)
+}
+
+// Staff syncs the active employees from Employee_Master into the User_Master
+// Staff view and the staff sheet.
+func Staff(kf *common.KissflowClient, opts StaffOptions) error {
+	cards := loadCards(opts.CardSources, opts.Cards)
+
+	staff, err := fetchAllStaff(kf, opts.Dataset, opts.View)
+	if err != nil {
+		return fmt.Errorf("unable to fetch staff: %w", err)
+	}
+	checkCards(cards, staffHolders(cards, staff))
+
+	// Prepare payloads for User_Master batch
+	var payloads []map[string]interface{}
+	for _, s := range staff {
+		payloads = append(payloads, mapStaffToUserMasterPayload(s, cards))
+	}
+
+	current, err := kf.FetchUserMasterRecords("Staff")
+	if err != nil {
+		return err
+	}
+	changes := common.Reconcile("Staff", current, payloads, common.ReconcileOptions{Deactivate: deactivation("no longer an active employee")})
+	applied, err := commit(kf, "staff", current, changes, opts.Options)
+	if err != nil || !applied {
+		return err
+	}
+
+	headers := []interface{}{"staffId", "Name", "jobTitle", "department", "IdentityNo", "IdentityType", "Gender", "CardNo"}
+	values := [][]interface{}{headers}
+	for _, s := range staff {
+		values = append(values, mapStaffToRow(s, cards))
+	}
+
+	srv, err := common.NewSheetsService(context.Background(), opts.Sheets.ServiceAccount)
+	if err != nil {
+		return err
+	}
+	if err := common.ReplaceSheetValues(srv, opts.Sheets.SpreadsheetID, opts.Sheets.StaffTab, values, 0); err != nil {
+		return err
+	}
+
+	log.Printf("Done! Wrote %d staff records to the sheet.", len(staff))
+	return nil
+}
+
+// staffId strips the leading "E" from an Employee_Master ID.
+func staffId(s StaffRecord) string {
+	id := s.Name
+	if len(id) > 1 && id[0] == 'E' {
+		id = id[1:]
+	}
+	return id
+}
+
+func mapStaffToRow(s StaffRecord, cards *common.CardRegistry) []interface{} {
+	gender := "2"
+	if s.Gender == "M" {
+		gender = "1"
+	}
+	id := staffId(s)
+	return []interface{}{
+		id,             // staffId (stripped E)
+		s.EmployeeName, // Name
+		s.Designation,  // jobTitle
+		s.Department,   // department
+		s.Email,        // IdentityNo
+		"3",            // IdentityType
+		gender,         // Gender
+		cards.Card(id), // CardNo
+	}
+}
+
+func mapStaffToUserMasterPayload(s StaffRecord, cards *common.CardRegistry) map[string]interface{} {
+	id := staffId(s)
+	return map[string]interface{}{
+		"_id":          id,
+		"Name":         id,
+		"Name_1":       s.EmployeeName,
+		"Type":         "3",
+		"Job_Title":    s.Designation,
+		"Department":   s.Department,
+		"IdentityNo":   "",
+		"IdentityType": "3",
+		"Status":       "1",
+		"Gender":       s.Gender,
+		"CardNo":       cards.Card(id),
+	}
+}
@@ -0,0 +1,170 @@
+package usersync
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"isams_to_sheets/src/common"
+)
+
+// StudentsOptions configures the students sync.
+type StudentsOptions struct {
+	Options
+	// CardSources supply the card numbers, in precedence order.
+	CardSources []common.CardSource
+	// PhotoConcurrency is the number of photos fetched at once.
+	PhotoConcurrency int
+}
+
+// Students syncs every iSAMS student, with photo and card number, into the
+// User_Master Students view and the students sheet.
+func Students(isams *common.ISAMSClient, kf *common.KissflowClient, opts StudentsOptions) error {
+	start := time.Now()
+	ctx := context.Background()
+
+	cards := loadCards(opts.CardSources, opts.Cards)
+
+	// Fetch students
+	students, err := isams.FetchAllStudents()
+	if err != nil {
+		return fmt.Errorf("unable to fetch students: %w", err)
+	}
+	checkCards(cards, studentHolders(cards, students))
+
+	// Fetch every photo once; both the payloads and the sheet rows use them.
+	schoolIds := make([]string, len(students))
+	for i, s := range students {
+		schoolIds[i] = s.SchoolId
+	}
+	photos := isams.FetchStudentPhotos(schoolIds, opts.PhotoConcurrency)
+
+	// Prepare payloads for User_Master batch
+	var payloads []map[string]interface{}
+	for _, s := range students {
+		payloads = append(payloads, mapStudentToUserMasterPayload(s, photos[s.SchoolId], cards))
+	}
+
+	// Bring User_Master in line with the Students API, touching only the
+	// records that actually changed.
+	current, err := kf.FetchUserMasterRecords("Students")
+	if err != nil {
+		return err
+	}
+	changes := common.Reconcile("Students", current, payloads, common.ReconcileOptions{PresenceOnly: []string{"image_1"}, Deactivate: deactivation("no longer a student in iSAMS")})
+	applied, err := commit(kf, "students", current, changes, opts.Options)
+	if err != nil || !applied {
+		return err
+	}
+
+	// Prepare data for sheets
+	headers := []interface{}{"schoolId", "Name", "type", "jobTitle", "department", "IdentityNo", "DateOfBirth", "IdentityType", "Status", "Gender", "FormGroup", "YearGroup", "CardNo", "photo", "photo_original_size", "photo_compressed_size", "photo_status", "photo_quality", "photo_dimensions"}
+	values := [][]interface{}{headers}
+
+	for _, s := range students {
+		values = append(values, mapStudentToRow(s, photos[s.SchoolId], cards))
+	}
+
+	// Write to Google Sheets
+	srv, err := common.NewSheetsService(ctx, opts.Sheets.ServiceAccount)
+	if err != nil {
+		return err
+	}
+	if err := common.ReplaceSheetValues(srv, opts.Sheets.SpreadsheetID, opts.Sheets.StudentsTab, values, opts.Sheets.BatchSize); err != nil {
+		return err
+	}
+
+	log.Printf("Done! Wrote %d students to the sheet in %s.", len(students), time.Since(start))
+	return nil
+}
+
+func mapStudentToRow(s common.Student, photo *common.Photo, cards *common.CardRegistry) []interface{} {
+	gender := "2"
+	if s.Gender == "M" {
+		gender = "1"
+	}
+	yearGroupStr := fmt.Sprintf("%v", s.YearGroup)
+	photoData := ""
+	origSize := 0
+	compSize := 0
+	status := "error"
+	quality := 0
+	dimensions := ""
+
+	if photo != nil {
+		photoData = photo.Base64Data
+		origSize = photo.OriginalSize
+		compSize = photo.CompressedSize
+		status = photo.Status
+		quality = photo.Quality
+		if photo.Width > 0 {
+			dimensions = fmt.Sprintf("%dx%d", photo.Width, photo.Height)
+		}
+	}
+
+	return []interface{}{
+		s.SchoolId,             // schoolId
+		s.FullName,             // Name
+		"1",                    // type
+		"",                     // jobTitle
+		s.FormGroup,            // department
+		"",                     // IdentityNo
+		s.DateOfBirth,          // DateOfBirth
+		"1",                    // IdentityType
+		"1",                    // Status
+		gender,                 // Gender
+		s.FormGroup,            // FormGroup
+		yearGroupStr,           // YearGroup
+		cards.Card(s.SchoolId), // CardNo
+		photoData,              // photo
+		origSize,               // photo_original_size
+		compSize,               // photo_compressed_size
+		status,                 // photo_status
+		quality,                // photo_quality
+		dimensions,             // photo_dimensions
+	}
+}
+
+// photoHashField holds the SHA-256 of the original photo behind image_1.
+// Kissflow stores image_1 as an attachment that cannot be compared with what
+// we send, so this is the field that makes a changed photo an update.
+const photoHashField = "PhotoSHA256"
+
+func mapStudentToUserMasterPayload(s common.Student, photo *common.Photo, cards *common.CardRegistry) map[string]interface{} {
+	schoolId := s.SchoolId
+	gender := "2"
+	if s.Gender == "M" {
+		gender = "1"
+	}
+	yearGroupStr := fmt.Sprintf("%v", s.YearGroup)
+	jobTitle := "JB"
+	if common.IsEPYearGroup(s.YearGroup) {
+		jobTitle = "EP"
+	}
+	payload := map[string]interface{}{
+		"_id":          schoolId,
+		"Name":         schoolId,
+		"Name_1":       strings.ToUpper(s.FullName),
+		"Type":         "1",
+		"Job_Title":    jobTitle,
+		"Department":   s.FormGroup,
+		"IdentityNo":   "",
+		"IdentityType": "1",
+		"FormGroup":    s.FormGroup,
+		"YearGroup":    yearGroupStr,
+		"Gender":       gender,
+		"DateOfBirth":  "",
+		"Status":       "1",
+		"AccessGroup":  "STUDENTS",
+		"CardNo":       cards.Card(schoolId),
+	}
+
+	if photo != nil && photo.IsValid() {
+		payload["image_1"] = photo.Base64Data
+		payload[photoHashField] = photo.SHA256
+	}
+
+	return payload
+}
@@ -0,0 +1,88 @@
+// Package usersync keeps the Kissflow User_Master dataset (and the matching
+// Google Sheets tabs) in line with iSAMS, Kissflow HR and the card exports.
+package usersync
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"isams_to_sheets/src/common"
+)
+
+// Options are shared by every sync.
+type Options struct {
+	// PlanPath, when set, writes the intended changes to a plan file and
+	// stops before anything is changed.
+	PlanPath string
+	// ForceDeletions overrides the mass-deletion guard.
+	ForceDeletions bool
+	// DryRun prints the intended changes and stops.
+	DryRun bool
+	// Output is the format of the change report: text or json.
+	Output string
+	// Sheets names the spreadsheet, tabs and service account key the syncs
+	// write to.
+	Sheets common.SheetsConfig
+	// Guard sets the mass-deletion guard limits.
+	Guard common.GuardConfig
+	// Cards sets the format card numbers are sent in.
+	Cards common.CardsConfig
+	// Out receives the change report. Defaults to stdout.
+	Out io.Writer
+}
+
+func (o Options) out() io.Writer {
+	if o.Out == nil {
+		return os.Stdout
+	}
+	return o.Out
+}
+
+// deactivation dates leavers today, with reason.
+func deactivation(reason string) *common.Deactivation {
+	return &common.Deactivation{Reason: reason, At: time.Now()}
+}
+
+// commit plans, previews or applies a changeset depending on the options. It
+// reports whether the changes were applied, so callers know whether to carry
+// on with their own side effects such as writing Sheets.
+func commit(kf *common.KissflowClient, command string, current []map[string]interface{}, changes *common.Changeset, opts Options) (bool, error) {
+	switch {
+	case opts.PlanPath != "":
+		if err := common.WritePlan(opts.PlanPath, common.NewPlan(command, current, changes)); err != nil {
+			return false, fmt.Errorf("failed to write plan: %w", err)
+		}
+		if err := common.PrintChangeset(opts.out(), changes, opts.Output); err != nil {
+			return false, err
+		}
+		fmt.Fprintf(os.Stderr, "Plan written to %s; run klass sync apply -plan %s to execute it.\n", opts.PlanPath, opts.PlanPath)
+		return false, nil
+	case opts.DryRun:
+		return false, common.PrintChangeset(opts.out(), changes, opts.Output)
+	}
+	if err := kf.ApplyChangeset(changes, common.NewDeletionGuard(command, opts.Guard, opts.ForceDeletions)); err != nil {
+		return false, fmt.Errorf("failed to apply User_Master changes: %w", err)
+	}
+	if opts.Output == common.OutputJSON {
+		return true, common.WriteJSON(opts.out(), changes)
+	}
+	return true, nil
+}
+
+// Apply executes a plan file written by one of the syncs with -plan.
+func Apply(kf *common.KissflowClient, planPath string, opts Options) error {
+	plan, err := common.ReadPlan(planPath)
+	if err != nil {
+		return err
+	}
+	if opts.DryRun {
+		return common.PrintChangeset(opts.out(), plan.Changes, opts.Output)
+	}
+	if err := kf.ApplyPlan(plan, common.NewDeletionGuard(plan.Command, opts.Guard, opts.ForceDeletions)); err != nil {
+		return fmt.Errorf("failed to apply plan: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Done! Applied %s plan from %s.\n", plan.Command, planPath)
+	return nil
+}